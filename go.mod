@@ -1,9 +1,8 @@
 module github.com/zipizapclouds/stateMxn
 
-go 1.19
+go 1.20
 
 require (
 	github.com/davecgh/go-spew v1.1.1
-	github.com/mohae/deepcopy v0.0.0-20170929034955-c48cc78d4826
 	github.com/trislu/plantuml v1.0.0
 )
@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"log"
+	"reflect"
 	"time"
 
 	"github.com/zipizapclouds/stateMxn/pkg/stateMxn"
@@ -246,7 +247,12 @@ func stateMxnGeneric_example4() {
 			outputs["fromAlpha int"] = 99
 			return nil
 		})
+	// The schemas declare the outputs that RunningAlpha promises, and the inputs that RunningBeta requires, so that
+	// the handlers can trust them (they are verified on activation, and drawn in the transitions-map plantuml)
+	runningAlphaState.PromiseOutput("fromAlpha", reflect.TypeOf(""))
+	runningAlphaState.PromiseOutput("fromAlpha int", reflect.TypeOf(0))
 	runningBetaState := stateMxn.NewState("RunningBeta")
+	runningBetaState.RequireInput("fromAlpha", reflect.TypeOf(""))
 	runningBetaState.AddHandlerExec(
 		func(inputs stateMxn.StateInputs, outputs stateMxn.StateOutputs, stateData stateMxn.StateData, smData stateMxn.StateMxnData) error {
 			// do beta processing...
@@ -400,6 +406,9 @@ func stateMxnGeneric_example5() {
 	}
 	fmt.Println(smxOutter.GetHistoryOfStates().DisplayStatesFlow())
 
+	// The smxInner that ran is not smxInner itself, but a new instance created from it when stateEnclosingSmxInner was activated
+	smxInner = smxOutter.GetHistoryOfStates()[1].GetData()["enclosedSmx"].(*stateMxn.StateMxnGeneric)
+
 	// Show plantUml diagrams
 	{
 		// 1.1) smxInner transitionMap
@@ -578,6 +587,9 @@ func stateMxnGeneric_example6() {
 		fmt.Println("SmxOutter \t currentStateName:", smxOutter.GetCurrentState().GetName()) // "FinishedOk"
 	}
 
+	// The smxInner that ran is not smxInner itself, but a new instance created from it when stateEnclosingSmxInner was activated
+	smxInner = smxOutter.GetHistoryOfStates()[1].GetData()["enclosedSmx"].(*stateMxn.StateMxnSimpleflow)
+
 	// Show plantUml diagrams
 	{
 		// 1.1) smxInner transitionMap
@@ -782,6 +794,9 @@ func stateMxnGeneric_example8() {
 	// err := smxOutter.ChangeToInitialStateAndAutoprogressToOtherStates("Init")
 	// logFatalIfError(err)
 
+	// The smxInner that ran is not smxInner itself, but a new instance created from it when stateEnclosingSmxInner was activated
+	smxInner = smxOutter.GetHistoryOfStates()[1].GetData()["enclosedSmx"].(*stateMxn.StateMxnSimpleflow)
+
 	// Show plantUml diagrams
 	_, smxInner_plantUmlUrl := smxInner.GetPlantUml()
 	fmt.Println(">> smxInner historyOfStates plantUmlUrl: \t", smxInner_plantUmlUrl)
@@ -801,12 +816,14 @@ func stateMxnGeneric_example9() {
 		"SmxInnerTf",
 		[]stateMxn.TrainMinistate{
 			{
-				"Check", func(inputs stateMxn.StateInputs, outputs stateMxn.StateOutputs, stateData stateMxn.StateData, smachineData stateMxn.StateMxnData) error {
+				StateName: "Check",
+				HandlerFunc: func(inputs stateMxn.StateInputs, outputs stateMxn.StateOutputs, stateData stateMxn.StateData, smachineData stateMxn.StateMxnData) error {
 					return nil
 				},
 			},
 			{
-				"Run", func(inputs stateMxn.StateInputs, outputs stateMxn.StateOutputs, stateData stateMxn.StateData, smachineData stateMxn.StateMxnData) error {
+				StateName: "Run",
+				HandlerFunc: func(inputs stateMxn.StateInputs, outputs stateMxn.StateOutputs, stateData stateMxn.StateData, smachineData stateMxn.StateMxnData) error {
 					return nil
 				},
 			},
@@ -842,7 +859,9 @@ func stateMxnGeneric_example9() {
 	*/
 
 	// Create stateEnclosingSmxInner (type *stateMxn.StateEnclosingSmxSimpleflow), using NewStateEnclosingSmxSimpleflow()
-	stateEnclosingSmxInner := stateMxn.NewStateEnclosingSmxSimpleflow("stateEnclosingSmxInner", smxInner, smxInnerInitialStateName)
+	// The initial state of a StateMxnTrainflow is its first ministate
+	smxInnerInitialStateName := "Check"
+	stateEnclosingSmxInner := stateMxn.NewStateEnclosingSmxSimpleflow("stateEnclosingSmxInner", smxInner.StateMxnSimpleflow, smxInnerInitialStateName)
 
 	// Create smxOutter, including stateEnclosingSmxInner in precreatedStates
 	smxOutterInitialStateName := "Init"
@@ -870,8 +889,11 @@ func stateMxnGeneric_example9() {
 	// err := smxOutter.ChangeToInitialStateAndAutoprogressToOtherStates("Init")
 	// logFatalIfError(err)
 
+	// The smxInner that ran is not smxInner itself, but a new instance created from it when stateEnclosingSmxInner was activated
+	smxInnerSf := smxOutter.GetHistoryOfStates()[1].GetData()["enclosedSmx"].(*stateMxn.StateMxnSimpleflow)
+
 	// Show plantUml diagrams
-	_, smxInner_plantUmlUrl := smxInner.GetPlantUml()
+	_, smxInner_plantUmlUrl := smxInnerSf.GetPlantUml()
 	fmt.Println(">> smxInner historyOfStates plantUmlUrl: \t", smxInner_plantUmlUrl)
 
 	_, smxOutter_plantUmlUrl := smxOutter.GetPlantUml()
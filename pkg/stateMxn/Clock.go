@@ -0,0 +1,134 @@
+package stateMxn
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time used by a smachine and its states (timestamps, timers, ...)
+//
+// Each smachine can have its own clock set with smg.SetClock(). When not set, the smachine will use the clock
+// inherited from an outter smachine (when it is an enclosedSmx), or otherwise the real clock.
+// The states of a smachine always use the clock of their smachine.
+//
+// Use NewFakeClock() in tests, to have deterministic timestamps and timers
+type Clock interface {
+	Now() time.Time
+
+	// AfterFunc waits for the duration d to elapse and then calls f in its own goroutine (see time.AfterFunc)
+	AfterFunc(d time.Duration, f func()) ClockTimer
+}
+
+// ClockTimer is a timer created by Clock.AfterFunc()
+type ClockTimer interface {
+	// Stop prevents the timer from firing. Returns false if the timer already fired or was already stopped
+	Stop() bool
+}
+
+// realClock is the default Clock, backed by package time
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+func (realClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	return time.AfterFunc(d, f)
+}
+
+// NewRealClock returns a Clock backed by package time. It is the default clock of any smachine
+func NewRealClock() Clock {
+	return realClock{}
+}
+
+/*
+FakeClock is a manually-driven Clock, meant for tests.
+
+Time only moves forward when calling fc.Advance() or fc.Set(), and any timers whose deadline is reached are fired
+synchronously (in the goroutine that moved the time), ordered by deadline.
+*/
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeClockTimer
+}
+
+type fakeClockTimer struct {
+	fc       *FakeClock
+	deadline time.Time
+	f        func()
+	stopped  bool
+}
+
+// NewFakeClock returns a FakeClock set at time now
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (fc *FakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+	return fc.now
+}
+
+func (fc *FakeClock) AfterFunc(d time.Duration, f func()) ClockTimer {
+	fc.mu.Lock()
+	t := &fakeClockTimer{fc: fc, deadline: fc.now.Add(d), f: f}
+	fc.timers = append(fc.timers, t)
+	fc.mu.Unlock()
+
+	if d <= 0 {
+		fc.fireDueTimers()
+	}
+	return t
+}
+
+// Advance moves the time forward by d, and fires any timers whose deadline was reached
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.mu.Lock()
+	fc.now = fc.now.Add(d)
+	fc.mu.Unlock()
+	fc.fireDueTimers()
+}
+
+// Set moves the time to t (which should not be before fc.Now()), and fires any timers whose deadline was reached
+func (fc *FakeClock) Set(t time.Time) {
+	fc.mu.Lock()
+	fc.now = t
+	fc.mu.Unlock()
+	fc.fireDueTimers()
+}
+
+// fireDueTimers calls (outside the lock) the f of every non-stopped timer with deadline <= fc.now, ordered by deadline
+func (fc *FakeClock) fireDueTimers() {
+	fc.mu.Lock()
+	var due, pending []*fakeClockTimer
+	for _, t := range fc.timers {
+		switch {
+		case t.stopped:
+			// discard
+		case !t.deadline.After(fc.now):
+			t.stopped = true
+			due = append(due, t)
+		default:
+			pending = append(pending, t)
+		}
+	}
+	fc.timers = pending
+	fc.mu.Unlock()
+
+	sort.SliceStable(due, func(i, j int) bool { return due[i].deadline.Before(due[j].deadline) })
+	for _, t := range due {
+		t.f()
+	}
+}
+
+func (t *fakeClockTimer) Stop() bool {
+	t.fc.mu.Lock()
+	defer t.fc.mu.Unlock()
+	if t.stopped {
+		return false
+	}
+	t.stopped = true
+	return true
+}
@@ -0,0 +1,96 @@
+package stateMxn
+
+import (
+	"testing"
+	"time"
+)
+
+var fakeClockStart = time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeClockFiresTimersInDeadlineOrder(t *testing.T) {
+	fc := NewFakeClock(fakeClockStart)
+	var fired []string
+	fc.AfterFunc(3*time.Second, func() { fired = append(fired, "3s") })
+	fc.AfterFunc(1*time.Second, func() { fired = append(fired, "1s") })
+	stopped := fc.AfterFunc(2*time.Second, func() { fired = append(fired, "2s") })
+	if !stopped.Stop() {
+		t.Fatal("Stop() of a pending timer returned false")
+	}
+
+	fc.Advance(500 * time.Millisecond)
+	if len(fired) != 0 {
+		t.Fatalf("fired %v before any deadline", fired)
+	}
+	fc.Advance(5 * time.Second)
+	if len(fired) != 2 || fired[0] != "1s" || fired[1] != "3s" {
+		t.Fatalf("fired %v - want [1s 3s]", fired)
+	}
+	if stopped.Stop() {
+		t.Error("Stop() of an already stopped timer returned true")
+	}
+	if got := fc.Now(); !got.Equal(fakeClockStart.Add(5500 * time.Millisecond)) {
+		t.Errorf("Now() = %s", got)
+	}
+}
+
+func newTimeoutSmx(t *testing.T, fc *FakeClock) *StateMxnGeneric {
+	t.Helper()
+	waiting := NewState("Waiting")
+	waiting.AddTimedTransition(10*time.Second, "TimedOut")
+	smg, err := NewStateMxnGeneric("timeoutSmx", map[string][]string{
+		"Waiting":  {"TimedOut", "Answered"},
+		"TimedOut": {},
+		"Answered": {},
+	}, map[string]StateIfc{"Waiting": waiting})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smg.SetClock(fc)
+	return smg
+}
+
+func TestTimedTransitionDrivenByFakeClock(t *testing.T) {
+	fc := NewFakeClock(fakeClockStart)
+	smg := newTimeoutSmx(t, fc)
+	if err := smg.Change("Waiting"); err != nil {
+		t.Fatal(err)
+	}
+
+	fc.Advance(9 * time.Second)
+	if is, _ := smg.Is("Waiting"); !is {
+		t.Fatalf("changed into %s before the deadline", smg.GetCurrentState().GetName())
+	}
+	fc.Advance(1 * time.Second)
+	if is, _ := smg.Is("TimedOut"); !is {
+		t.Fatalf("current state = %s - want TimedOut", smg.GetCurrentState().GetName())
+	}
+
+	// the timestamps come from the fake clock
+	history := smg.GetHistoryOfStates()
+	if got := history[0].GetData()["timeStart"].(time.Time); !got.Equal(fakeClockStart) {
+		t.Errorf("Waiting timeStart = %s - want %s", got, fakeClockStart)
+	}
+	firing := history[1].GetData()["firedTimedTransition"].(TimedTransitionFiring)
+	if firing.SourceStateName != "Waiting" || !firing.FiredAt.Equal(fakeClockStart.Add(10*time.Second)) {
+		t.Errorf("firedTimedTransition = %+v", firing)
+	}
+}
+
+func TestTimedTransitionCancelledWhenLeavingTheState(t *testing.T) {
+	fc := NewFakeClock(fakeClockStart)
+	smg := newTimeoutSmx(t, fc)
+	if err := smg.Change("Waiting"); err != nil {
+		t.Fatal(err)
+	}
+	fc.Advance(5 * time.Second)
+	if err := smg.Change("Answered"); err != nil {
+		t.Fatal(err)
+	}
+	fc.Advance(time.Minute)
+	if is, _ := smg.Is("Answered"); !is {
+		t.Fatalf("current state = %s - want Answered", smg.GetCurrentState().GetName())
+	}
+	if len(smg.GetHistoryOfStates()) != 2 {
+		t.Errorf("history = %s", smg.GetHistoryOfStates().DisplayStatesFlow())
+	}
+}
@@ -0,0 +1,300 @@
+package stateMxn
+
+import (
+	"reflect"
+	"sync"
+)
+
+/*
+Copier is the strategy used by a smachine to copy:
+  - the inputs of each state (from the outputs of the previous state), so that a state does not modify the outputs of another state
+  - the inputs, outputs and data of the precreated-states, into the states that are activated
+
+Set it per smachine with smg.SetCopier(). When not set, both the inputs and the precreated-states are deep-copied (NewDeepCopier())
+
+Available strategies:
+  - NewDeepCopier()        deep-copies every value, except the structs with unexported fields (see NewDeepCopier())
+  - NewShallowCopier()     copies only the map, the values are shared
+  - NewClonerCopier()      copies the map, and clones the values that implement Cloner (other values are shared)
+  - NewCopyOnWriteCopier() copies the map, and shares the *CowValue values until one of the copies is mutated (other values are shared)
+*/
+type Copier interface {
+	Copy(m map[string]interface{}) map[string]interface{}
+}
+
+// Cloner is implemented by types that know how to copy themselves (including their unexported fields)
+type Cloner interface {
+	Clone() interface{}
+}
+
+type deepCopier struct{}
+
+// NewDeepCopier returns a Copier that deep-copies every value: maps, slices, arrays, pointers, interfaces and structs are copied
+// recursively, and values implementing Cloner are cloned.
+//
+// The unexported fields of a struct cannot be set with reflection, so a struct with unexported fields (ex: a time.Time) is copied
+// by value, and a pointer to such a struct (ex: a *bytes.Buffer) is shared - implement Cloner for those types to have them copied.
+// Channels and funcs are shared
+func NewDeepCopier() Copier {
+	return deepCopier{}
+}
+
+func (deepCopier) Copy(m map[string]interface{}) map[string]interface{} {
+	mCopy := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		mCopy[k] = deepCopy(v)
+	}
+	return mCopy
+}
+
+// Returns a deep copy of v, as described in NewDeepCopier()
+func deepCopy(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return deepCopyValue(reflect.ValueOf(v), make(map[deepCopyVisit]reflect.Value)).Interface()
+}
+
+// deepCopyVisit - a pointer (or map) already copied, so that cycles and shared references are copied only once
+type deepCopyVisit struct {
+	typ reflect.Type
+	ptr uintptr
+}
+
+func deepCopyValue(v reflect.Value, copies map[deepCopyVisit]reflect.Value) reflect.Value {
+	if v.Kind() != reflect.Interface && v.CanInterface() && !isNilValue(v) {
+		if c, ok := v.Interface().(Cloner); ok {
+			if cloned := reflect.ValueOf(c.Clone()); cloned.IsValid() && cloned.Type().AssignableTo(v.Type()) {
+				return cloned
+			}
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() || isOpaqueStruct(v.Type().Elem()) {
+			return v
+		}
+		visit := deepCopyVisit{typ: v.Type(), ptr: v.Pointer()}
+		if vCopy, ok := copies[visit]; ok {
+			return vCopy
+		}
+		vCopy := reflect.New(v.Type().Elem())
+		copies[visit] = vCopy
+		vCopy.Elem().Set(deepCopyValue(v.Elem(), copies))
+		return vCopy
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		vCopy := reflect.New(v.Type()).Elem()
+		vCopy.Set(deepCopyValue(v.Elem(), copies))
+		return vCopy
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		visit := deepCopyVisit{typ: v.Type(), ptr: v.Pointer()}
+		if vCopy, ok := copies[visit]; ok {
+			return vCopy
+		}
+		vCopy := reflect.MakeMapWithSize(v.Type(), v.Len())
+		copies[visit] = vCopy
+		iter := v.MapRange()
+		for iter.Next() {
+			vCopy.SetMapIndex(iter.Key(), deepCopyValue(iter.Value(), copies))
+		}
+		return vCopy
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		vCopy := reflect.MakeSlice(v.Type(), v.Len(), v.Cap())
+		for i := 0; i < v.Len(); i++ {
+			vCopy.Index(i).Set(deepCopyValue(v.Index(i), copies))
+		}
+		return vCopy
+	case reflect.Array:
+		vCopy := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			vCopy.Index(i).Set(deepCopyValue(v.Index(i), copies))
+		}
+		return vCopy
+	case reflect.Struct:
+		if isOpaqueStruct(v.Type()) {
+			return v
+		}
+		vCopy := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.NumField(); i++ {
+			vCopy.Field(i).Set(deepCopyValue(v.Field(i), copies))
+		}
+		return vCopy
+	default:
+		// basic types are copied by value, and channels, funcs and unsafe.Pointers are shared
+		return v
+	}
+}
+
+// Returns true if v is a nil pointer, map, slice, interface, channel or func
+func isNilValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Chan, reflect.Func:
+		return v.IsNil()
+	}
+	return false
+}
+
+// cache of isOpaqueStruct(), per reflect.Type
+var opaqueStructCache sync.Map
+
+// Returns true if typ is a struct with unexported fields, which cannot be deep-copied with reflection
+func isOpaqueStruct(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct {
+		return false
+	}
+	if cached, ok := opaqueStructCache.Load(typ); ok {
+		return cached.(bool)
+	}
+	opaque := false
+	for i := 0; i < typ.NumField(); i++ {
+		if !typ.Field(i).IsExported() {
+			opaque = true
+			break
+		}
+	}
+	opaqueStructCache.Store(typ, opaque)
+	return opaque
+}
+
+type shallowCopier struct{}
+
+// NewShallowCopier returns a Copier that creates a new map with the same values
+func NewShallowCopier() Copier {
+	return shallowCopier{}
+}
+
+func (shallowCopier) Copy(m map[string]interface{}) map[string]interface{} {
+	mCopy := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		mCopy[k] = v
+	}
+	return mCopy
+}
+
+type clonerCopier struct{}
+
+// NewClonerCopier returns a Copier that creates a new map where the values implementing Cloner are cloned, and the others are shared
+func NewClonerCopier() Copier {
+	return clonerCopier{}
+}
+
+func (clonerCopier) Copy(m map[string]interface{}) map[string]interface{} {
+	mCopy := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if c, ok := v.(Cloner); ok {
+			mCopy[k] = c.Clone()
+		} else {
+			mCopy[k] = v
+		}
+	}
+	return mCopy
+}
+
+type copyOnWriteCopier struct{}
+
+// NewCopyOnWriteCopier returns a Copier that creates a new map where the *CowValue values share their underlying value with the
+// original (until one of them is mutated with cv.Mutate()), and the other values are shared
+func NewCopyOnWriteCopier() Copier {
+	return copyOnWriteCopier{}
+}
+
+func (copyOnWriteCopier) Copy(m map[string]interface{}) map[string]interface{} {
+	mCopy := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if cv, ok := v.(*CowValue); ok {
+			mCopy[k] = cv.share()
+		} else {
+			mCopy[k] = v
+		}
+	}
+	return mCopy
+}
+
+/*
+CowValue is a copy-on-write container, for big values passed between states with NewCopyOnWriteCopier().
+
+Copies of a CowValue share the same underlying value, which is only copied (cloned if it implements Cloner, or otherwise deep-copied)
+when a copy is mutated with cv.Mutate() while its still shared. The value returned by cv.Get() must be treated as read-only.
+
+	outputs["records"] = stateMxn.NewCowValue(records)
+	...
+	inputs["records"].(*stateMxn.CowValue).Mutate(func(v interface{}) interface{} { return append(v.([]Record), newRecord) })
+*/
+type CowValue struct {
+	mu     sync.Mutex
+	shared *cowShared
+}
+
+type cowShared struct {
+	value interface{}
+	// refs - number of CowValue's sharing this value
+	refs int
+}
+
+var cowMu sync.Mutex // protects cowShared.refs, which is shared between CowValue's
+
+func NewCowValue(value interface{}) *CowValue {
+	return &CowValue{shared: &cowShared{value: value, refs: 1}}
+}
+
+// Get returns the value, which must not be modified (use cv.Mutate() instead)
+func (cv *CowValue) Get() interface{} {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	return cv.shared.value
+}
+
+// Set replaces the value of cv (without affecting its copies)
+func (cv *CowValue) Set(value interface{}) {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	cowMu.Lock()
+	cv.shared.refs--
+	cowMu.Unlock()
+	cv.shared = &cowShared{value: value, refs: 1}
+}
+
+// Mutate replaces the value of cv with mutateFunc(value). If the value is shared with copies of cv, then mutateFunc receives a
+// private copy of the value, so the copies are not affected
+func (cv *CowValue) Mutate(mutateFunc func(value interface{}) interface{}) {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	cowMu.Lock()
+	isShared := cv.shared.refs > 1
+	if isShared {
+		cv.shared.refs--
+	}
+	cowMu.Unlock()
+
+	value := cv.shared.value
+	if isShared {
+		value = deepCopy(value)
+	}
+	cv.shared = &cowShared{value: mutateFunc(value), refs: 1}
+}
+
+// Clone implements Cloner, returning a copy of cv that shares its value until one of them is mutated (like NewCopyOnWriteCopier() does).
+// This way a CowValue is never deep-copied, which would lose its unexported fields
+func (cv *CowValue) Clone() interface{} {
+	return cv.share()
+}
+
+// Returns a new CowValue sharing the value of cv
+func (cv *CowValue) share() *CowValue {
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	cowMu.Lock()
+	cv.shared.refs++
+	cowMu.Unlock()
+	return &CowValue{shared: cv.shared}
+}
@@ -0,0 +1,95 @@
+package stateMxn
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestDeepCopierCopiesCowValue(t *testing.T) {
+	cv := NewCowValue([]int{1, 2})
+	m := NewDeepCopier().Copy(map[string]interface{}{"cv": cv})
+	cvCopy, ok := m["cv"].(*CowValue)
+	if !ok || cvCopy == cv {
+		t.Fatalf("copy of a *CowValue = %#v - want another *CowValue", m["cv"])
+	}
+	// would panic if the copy lost its unexported fields
+	if got := cvCopy.Get().([]int); len(got) != 2 {
+		t.Fatalf("cvCopy.Get() = %v", got)
+	}
+	cvCopy.Mutate(func(v interface{}) interface{} { return append(v.([]int), 3) })
+	if len(cv.Get().([]int)) != 2 || len(cvCopy.Get().([]int)) != 3 {
+		t.Errorf("after mutating the copy: cv = %v, cvCopy = %v", cv.Get(), cvCopy.Get())
+	}
+}
+
+func TestDeepCopierSharesValuesWithUnexportedFields(t *testing.T) {
+	buf := bytes.NewBufferString("user data")
+	records := []map[string]int{{"a": 1}}
+	m := NewDeepCopier().Copy(map[string]interface{}{"buf": buf, "records": records})
+
+	if m["buf"].(*bytes.Buffer) != buf {
+		t.Error("a *bytes.Buffer was copied - its unexported fields would be lost")
+	}
+	if buf.String() != "user data" {
+		t.Errorf("buf = %q", buf.String())
+	}
+	recordsCopy := m["records"].([]map[string]int)
+	recordsCopy[0]["a"] = 2
+	if records[0]["a"] != 1 {
+		t.Error("records (without unexported fields) were not deep-copied")
+	}
+}
+
+type copierOrder struct {
+	Items  []string
+	When   time.Time
+	Parent *copierOrder
+}
+
+func TestDeepCopierCopiesStructsContainingOpaqueStructs(t *testing.T) {
+	when := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	order := &copierOrder{Items: []string{"a"}, When: when}
+	order.Parent = order
+	m := NewDeepCopier().Copy(map[string]interface{}{"order": order, "orders": []interface{}{order}})
+
+	orderCopy := m["order"].(*copierOrder)
+	if orderCopy == order {
+		t.Fatal("a *copierOrder (with a time.Time field) was shared instead of copied")
+	}
+	orderCopy.Items[0] = "b"
+	if order.Items[0] != "a" {
+		t.Error("the copy shares its Items with the original")
+	}
+	if !orderCopy.When.Equal(when) {
+		t.Errorf("orderCopy.When = %s - want %s", orderCopy.When, when)
+	}
+	if orderCopy.Parent != orderCopy {
+		t.Error("the cycle of the original was not kept in the copy")
+	}
+	if m["orders"].([]interface{})[0].(*copierOrder) == order {
+		t.Error("a *copierOrder inside an interface was shared instead of copied")
+	}
+}
+
+func TestPrecreatedStatesAreDeepCopied(t *testing.T) {
+	state := NewState("Init")
+	state.GetData()["items"] = []string{"a"}
+	state.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+		stateData["items"].([]string)[0] = "modified"
+		return nil
+	})
+	def, err := NewStateMxnDefinition("smx", map[string][]string{"Init": {"Done"}}, map[string]StateIfc{"Init": state})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"first", "second"} {
+		smg := def.NewInstance(id)
+		if err := smg.Change("Init"); err != nil {
+			t.Fatal(err)
+		}
+		if got := def.precreatedStates["Init"].GetData()["items"].([]string)[0]; got != "a" {
+			t.Fatalf("instance %s modified the precreated-state: items[0] = %q", id, got)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package stateMxn
+
+import "fmt"
+
+/*
+EnclosedSmxDefinition creates the enclosedSmx of a state: each time the state is activated (in any instance of its smachine), a new
+enclosedSmx is created with NewEnclosedSmx() and stored in the data["enclosedSmx"] of the activated state, so that the instances
+never share an enclosedSmx. Set it with state.SetEnclosedSmxDefinition()
+
+Its implemented by StateMxnDefinition, StateMxnSimpleflowDefinition and StateMxnTrainflowDefinition (which create a new instance
+of themselves, of the same type)
+
+A precreated-state with a smachine directly in its data["enclosedSmx"] (as in main.go example5) uses that smachine as a template:
+each activation creates a new instance of the same type and definition, with the same settings (clock, copier, middlewares, ...)
+*/
+type EnclosedSmxDefinition interface {
+	// NewEnclosedSmx creates a new enclosedSmx, identified by id
+	NewEnclosedSmx(id string) StateMxnIfc
+}
+
+func (def *StateMxnDefinition) NewEnclosedSmx(id string) StateMxnIfc {
+	return def.NewInstance(id)
+}
+
+func (def *StateMxnSimpleflowDefinition) NewEnclosedSmx(id string) StateMxnIfc {
+	return def.NewInstance(id)
+}
+
+func (def *StateMxnTrainflowDefinition) NewEnclosedSmx(id string) StateMxnIfc {
+	return def.NewInstance(id)
+}
+
+// enclosedSmxTemplate - the EnclosedSmxDefinition of a precreated-state with a smachine in its data["enclosedSmx"]
+type enclosedSmxTemplate struct {
+	template *StateMxnGeneric
+	// newInstance - creates a new instance with the same type and definition of the template
+	newInstance func(id string) StateMxnIfc
+}
+
+// Returns error if the type of template is not one of this package (as then it cannot be instantiated)
+func newEnclosedSmxTemplate(template StateMxnIfc) (*enclosedSmxTemplate, error) {
+	t := &enclosedSmxTemplate{}
+	switch smx := template.(type) {
+	case *StateMxnTrainflow:
+		def := &StateMxnTrainflowDefinition{
+			StateMxnSimpleflowDefinition: &StateMxnSimpleflowDefinition{StateMxnDefinition: smx.definition},
+			trainOfMinistates:            smx.trainOfMinistates,
+		}
+		t.template = smx.StateMxnGeneric
+		t.newInstance = def.NewEnclosedSmx
+	case *StateMxnSimpleflow:
+		def := &StateMxnSimpleflowDefinition{StateMxnDefinition: smx.definition}
+		t.template = smx.StateMxnGeneric
+		t.newInstance = def.NewEnclosedSmx
+	case *StateMxnGeneric:
+		t.template = smx
+		t.newInstance = smx.definition.NewEnclosedSmx
+	default:
+		return nil, fmt.Errorf("enclosedSmx of type %T cannot be created for each activation - use state.SetEnclosedSmxDefinition() instead", template)
+	}
+	return t, nil
+}
+
+func (t *enclosedSmxTemplate) NewEnclosedSmx(id string) StateMxnIfc {
+	eSmx := t.newInstance(id)
+	eSmg, _ := asStateMxnGeneric(eSmx)
+	eSmg.copySettingsFrom(t.template)
+	return eSmx
+}
+
+// Copies into smg the settings of template (set with smg.SetClock(), smg.Use(), ...), and a shallow copy of its data.
+// The store is not copied, as each instance must be saved with its own id
+func (smg *StateMxnGeneric) copySettingsFrom(template *StateMxnGeneric) {
+	smg.clock = template.clock
+	smg.inputMapping = template.inputMapping
+	smg.transitionInputMappings = make(map[string]map[string]InputMapping)
+	for source, mappings := range template.transitionInputMappings {
+		smg.transitionInputMappings[source] = make(map[string]InputMapping)
+		for destination, inputMapping := range mappings {
+			smg.transitionInputMappings[source][destination] = inputMapping
+		}
+	}
+	smg.initialInputs = template.initialInputs
+	smg.middlewares = append([]usedMiddleware{}, template.middlewares...)
+	smg.metricsExporter = template.metricsExporter
+	smg.recoverPanics = template.recoverPanics
+	smg.verboseErrors = template.verboseErrors
+	smg.transitionActions = make(map[string]map[string][]transitionAction)
+	for source, actions := range template.transitionActions {
+		smg.transitionActions[source] = make(map[string][]transitionAction)
+		for destination, destinationActions := range actions {
+			smg.transitionActions[source][destination] = append([]transitionAction{}, destinationActions...)
+		}
+	}
+	smg.strictMode = template.strictMode
+	smg.dataKeyOwners = make(map[string][]string)
+	for key, stateNames := range template.dataKeyOwners {
+		smg.dataKeyOwners[key] = append([]string{}, stateNames...)
+	}
+	smg.recordDataDiffs = template.recordDataDiffs
+	smg.copier = template.copier
+	smg.coverage = template.coverage
+	for k, v := range template.data {
+		smg.data[k] = v
+	}
+}
@@ -0,0 +1,139 @@
+package stateMxn
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/davecgh/go-spew/spew"
+)
+
+// Sentinel errors, wrapped by the errors returned by the smachines. Match them with errors.Is()
+var (
+	// ErrUnknownState - the state name is not in the transitionsMap
+	ErrUnknownState = errors.New("unknown state")
+	// ErrInvalidSource - the state has no transitions in the transitionsMap (ie, its not a source)
+	ErrInvalidSource = errors.New("invalid source state")
+	// ErrInvalidTransition - the transition (or the initial state) is not allowed by the transitionsMap (or the declared initial states)
+	ErrInvalidTransition = errors.New("invalid transition")
+	// ErrChangeNotAllowed - the smachine does not allow state-changes now (its suspended, in a final state, or its
+	// a StateMxnSimpleflow/StateMxnTrainflow where Change() is not allowed)
+	ErrChangeNotAllowed = errors.New("state-change not allowed")
+	// ErrStrictModeViolation - a handler modified its inputs, or wrote a key of smx.data it does not own (see StateMxnGenericStrict.go)
+	ErrStrictModeViolation = errors.New("strict-mode violation")
+)
+
+// HandlerPhase is the phase of a handler, which is also its key in State.handlers
+type HandlerPhase string
+
+const (
+	HandlerPhaseBegin HandlerPhase = "begin"
+	HandlerPhaseExec  HandlerPhase = "exec"
+	HandlerPhaseEnd   HandlerPhase = "end"
+	// HandlerPhaseOnError - the phase of the onError-handlers (see State.AddHandlerOnError()), which is not a key in State.handlers
+	HandlerPhaseOnError HandlerPhase = "onError"
+)
+
+/*
+HandlerError wraps the error returned by a handler of a state. Match it with errors.As(), and the original error with
+errors.Is()/errors.As() (see Unwrap())
+
+NestingPath is the path of smachines and states down to this handler, starting at the outtermost smachine. Ex, for a handler of
+state "Running" of smachine "SmxInner", enclosed in state "stateEnclosingSmxInner" of smachine "SmxOutter":
+
+	[]string{"SmxOutter", "stateEnclosingSmxInner", "SmxInner", "Running"}
+*/
+type HandlerError struct {
+	SmxName      string
+	StateName    string
+	Phase        HandlerPhase
+	HandlerIndex int    // index of the handler in its phase, in execution order
+	HandlerName  string // empty for handlers added without name (see State.AddHandlerExecNamed())
+	NestingPath  []string
+	Err          error
+
+	// Panicked is true when the handler panicked (and the panic was recovered, see smg.SetRecoverPanics()). Then PanicValue
+	// is the value passed to panic(), and Stack is the stack trace of the panic
+	Panicked   bool
+	PanicValue interface{}
+	Stack      []byte
+}
+
+func (he *HandlerError) Error() string {
+	handlerName := ""
+	if he.HandlerName != "" {
+		handlerName = " '" + he.HandlerName + "'"
+	}
+	return fmt.Sprintf("%s-handler[%d]%s of state '%s' (%s): %s", he.Phase, he.HandlerIndex, handlerName, he.StateName, strings.Join(he.NestingPath, "/"), he.Err)
+}
+
+func (he *HandlerError) Unwrap() error {
+	return he.Err
+}
+
+// SetRecoverPanics enables (the default) or disables the recovery of panics in the handlers of the states of smg. When enabled,
+// a panic is returned as a HandlerError with Panicked=true and the end-handlers are still executed. Disable it to let panics
+// crash the process (ex: in debug builds)
+func (smg *StateMxnGeneric) SetRecoverPanics(recoverPanics bool) {
+	smg.recoverPanics = recoverPanics
+}
+
+// SetVerboseErrors makes the ErrUnknownState, ErrInvalidSource and ErrInvalidTransition errors include a dump of the
+// transitionsMap (disabled by default)
+func (smg *StateMxnGeneric) SetVerboseErrors(verbose bool) {
+	smg.verboseErrors = verbose
+}
+
+// Returns the detail appended to the verify* errors: the transitionsMap dump, when smg.SetVerboseErrors(true)
+func (smg *StateMxnGeneric) errorDetail() string {
+	if !smg.verboseErrors {
+		return ""
+	}
+	return "\nThe transitionsMap is:\n" + spew.Sdump(smg.transitionsMap)
+}
+
+// Returns the nesting path of smg (see HandlerError.NestingPath), ending with smg name
+func (smg *StateMxnGeneric) getNestingPath() []string {
+	return append(append([]string{}, smg.outterNestingPath...), smg.GetName())
+}
+
+// Returns true if err is in errs (ex: the error returned by a state, which is also one of the errors of that state)
+// The errors are compared with sameValue(), as == panics with errors of comparable types holding uncomparable values
+func containsError(errs []error, err error) bool {
+	for _, e := range errs {
+		if sameValue(e, err) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns nil if errs is empty, errs[0] if it has a single error, or else errors.Join(errs...)
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	return errors.Join(errs...)
+}
+
+// Returns the list of errors stored in data (see State.setError() and StateMxnGeneric.setError())
+func getErrorsFromData(data map[string]interface{}) []error {
+	if errs, ok := data["errors"].([]error); ok {
+		return append([]error{}, errs...)
+	}
+	if err, ok := data["error"].(error); ok {
+		return []error{err}
+	}
+	return nil
+}
+
+// Appends err into data["errors"], and sets data["error"] with all the errors joined (see joinErrors())
+func setErrorInData(data map[string]interface{}, err error) {
+	errs, _ := data["errors"].([]error)
+	errs = append(errs, err)
+	data["errors"] = errs
+	data["error"] = joinErrors(errs)
+}
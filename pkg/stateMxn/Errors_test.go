@@ -0,0 +1,237 @@
+package stateMxn
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// a comparable type, which == panics on when details holds an uncomparable value
+type detailedError struct {
+	details interface{}
+}
+
+func (de detailedError) Error() string {
+	return "detailed error"
+}
+
+func TestSetErrorInDataWithUncomparableDynamicValues(t *testing.T) {
+	err1 := detailedError{details: []string{"a"}}
+	err2 := detailedError{details: map[string]int{"b": 1}}
+	errSentinel := errors.New("sentinel")
+
+	data := make(map[string]interface{})
+	for _, err := range []error{err1, err2, err1, errSentinel, errSentinel} {
+		setErrorInData(data, err)
+	}
+	// every error is appended, even if it was already stored (ex: the same error of 2 different states)
+	if errs := getErrorsFromData(data); len(errs) != 5 {
+		t.Errorf("errs = %v - want 5 errors", errs)
+	}
+	if containsError([]error{err1, errSentinel}, err2) || !containsError([]error{err1, errSentinel}, errSentinel) {
+		t.Error("containsError() with uncomparable dynamic values gave a wrong result")
+	}
+}
+
+func TestStateErrorIsStoredOnceInTheSmachine(t *testing.T) {
+	errSentinel := errors.New("sentinel")
+	failing := func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		return errSentinel
+	}
+	running := NewState("Running")
+	running.AddHandlerExec(failing)
+	retry := NewState("Retry")
+	retry.AddHandlerExec(failing)
+	smg, err := NewStateMxnGeneric("smx", map[string][]string{
+		"Running": {"Retry"},
+		"Retry":   {"Finished"},
+	}, map[string]StateIfc{"Running": running, "Retry": retry})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = smg.Change("Running")
+	if errs := smg.GetErrors(); len(errs) != 1 {
+		t.Errorf("smx errors after 1 failed state = %v - want 1 error", errs)
+	}
+	_ = smg.Change("Retry")
+	if errs := smg.GetErrors(); len(errs) != 2 {
+		t.Errorf("smx errors after 2 failed states = %v - want 2 errors", errs)
+	}
+
+	smsf, err := NewStateMxnSimpleFlow("smsf", map[string][]string{
+		"Running": {"FinishedOk", "FinishedNok"},
+	}, map[string]StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = smsf.ChangeToInitialStateAndAutoprogressToOtherStates("Running")
+	if errs := smsf.GetErrors(); len(errs) != 1 {
+		t.Errorf("simpleflow smx errors after 1 failed state = %v - want 1 error", errs)
+	}
+}
+
+func TestSentinelErrorsMatchWithErrorsIs(t *testing.T) {
+	smg, err := NewStateMxnGeneric("smx", map[string][]string{
+		"Init":    {"Running"},
+		"Running": {"Finished"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := smg.Change("Bogus"); !errors.Is(err, ErrUnknownState) {
+		t.Errorf("Change(Bogus) = %v - want ErrUnknownState", err)
+	}
+	if err := smg.Change("Init"); err != nil {
+		t.Fatal(err)
+	}
+	if err := smg.Change("Finished"); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("Change(Finished) from Init = %v - want ErrInvalidTransition", err)
+	}
+	// a state without transitions is final, so Change() rejects it as FinalStateError before verifying the source
+	if err := smg.verifyIfValidSourcestate("Finished"); !errors.Is(err, ErrInvalidSource) {
+		t.Errorf("verifyIfValidSourcestate(Finished) = %v - want ErrInvalidSource", err)
+	}
+	smg.Suspend()
+	if err := smg.Change("Running"); !errors.Is(err, ErrChangeNotAllowed) {
+		t.Errorf("Change() while suspended = %v - want ErrChangeNotAllowed", err)
+	}
+	smg.Resume()
+	_ = smg.Change("Running")
+	_ = smg.Change("Finished")
+	if err := smg.Change("Running"); !errors.Is(err, ErrChangeNotAllowed) {
+		t.Errorf("Change() out of a final state = %v - want ErrChangeNotAllowed", err)
+	}
+
+	smsf, err := NewStateMxnSimpleFlow("smsf", map[string][]string{
+		"Init": {"FinishedOk", "FinishedNok"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := smsf.Change("Init"); !errors.Is(err, ErrChangeNotAllowed) {
+		t.Errorf("Change() of a StateMxnSimpleflow = %v - want ErrChangeNotAllowed", err)
+	}
+}
+
+func TestHandlerErrorOfAnEnclosedSmx(t *testing.T) {
+	errRunning := errors.New("running fails")
+	running := NewState("Running")
+	running.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		return nil
+	})
+	running.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		return errRunning
+	})
+	innerDef, err := NewStateMxnDefinition("SmxInner", map[string][]string{
+		"Running": {"Finished"},
+	}, map[string]StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enclosing := NewState("Enclosing")
+	enclosing.SetEnclosedSmxDefinition(innerDef)
+	enclosing.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		return stateData["enclosedSmx"].(StateMxnIfc).Change("Running")
+	})
+	smxOutter, err := NewStateMxnGeneric("SmxOutter", map[string][]string{
+		"Enclosing": {"Finished"},
+	}, map[string]StateIfc{"Enclosing": enclosing})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = smxOutter.Change("Enclosing")
+	if !errors.Is(err, errRunning) {
+		t.Fatalf("Change() = %v - want it to wrap errRunning", err)
+	}
+	var outterHe *HandlerError
+	if !errors.As(err, &outterHe) {
+		t.Fatalf("Change() = %v - want a HandlerError", err)
+	}
+	if outterHe.SmxName != "SmxOutter" || outterHe.StateName != "Enclosing" || outterHe.Phase != HandlerPhaseExec || outterHe.HandlerIndex != 0 {
+		t.Errorf("outter HandlerError = %+v", outterHe)
+	}
+	var innerHe *HandlerError
+	if !errors.As(outterHe.Err, &innerHe) {
+		t.Fatalf("outter HandlerError wraps %v - want the HandlerError of the enclosedSmx", outterHe.Err)
+	}
+	if innerHe.SmxName != "SmxInner" || innerHe.StateName != "Running" || innerHe.Phase != HandlerPhaseExec || innerHe.HandlerIndex != 1 {
+		t.Errorf("inner HandlerError = %+v", innerHe)
+	}
+	if want := []string{"SmxOutter", "Enclosing", "SmxInner", "Running"}; !reflect.DeepEqual(innerHe.NestingPath, want) {
+		t.Errorf("inner NestingPath = %v - want %v", innerHe.NestingPath, want)
+	}
+}
+
+func TestVerboseErrorsIncludeTheTransitionsMap(t *testing.T) {
+	smg, err := NewStateMxnGeneric("smx", map[string][]string{
+		"Init": {"Finished"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := smg.Change("Bogus"); err == nil || strings.Contains(err.Error(), "The transitionsMap is") {
+		t.Errorf("Change(Bogus) without verbose errors = %v", err)
+	}
+	smg.SetVerboseErrors(true)
+	if err := smg.Change("Bogus"); err == nil || !strings.Contains(err.Error(), "The transitionsMap is") || !strings.Contains(err.Error(), "Finished") {
+		t.Errorf("Change(Bogus) with verbose errors = %v - want the transitionsMap dump", err)
+	}
+}
+
+func newPanickingSmx(t *testing.T) *StateMxnGeneric {
+	t.Helper()
+	running := NewState("Running")
+	running.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		panic("boom")
+	})
+	running.AddHandlerEnd(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		stateData["endRan"] = true
+		return nil
+	})
+	smg, err := NewStateMxnGeneric("smx", map[string][]string{
+		"Running": {"Retry", "Finished"},
+		"Retry":   {"Finished"},
+	}, map[string]StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return smg
+}
+
+func TestPanicInHandlerIsRecoveredAsHandlerError(t *testing.T) {
+	smg := newPanickingSmx(t)
+	err := smg.Change("Running")
+	var he *HandlerError
+	if !errors.As(err, &he) {
+		t.Fatalf("Change() = %v - want a HandlerError", err)
+	}
+	if !he.Panicked || he.PanicValue != "boom" || len(he.Stack) == 0 || he.Phase != HandlerPhaseExec {
+		t.Errorf("HandlerError = %+v - want Panicked with PanicValue 'boom' and a Stack", he)
+	}
+	if endRan, _ := smg.GetCurrentState().GetData()["endRan"].(bool); !endRan {
+		t.Error("the end-handler did not run after the panic")
+	}
+
+	// the smachine is still usable
+	if err := smg.Change("Retry"); err != nil {
+		t.Fatalf("Change(Retry) after the panic = %v", err)
+	}
+	if err := smg.Change("Finished"); err != nil {
+		t.Fatalf("Change(Finished) after the panic = %v", err)
+	}
+}
+
+func TestPanicInHandlerIsNotRecoveredWhenDisabled(t *testing.T) {
+	smg := newPanickingSmx(t)
+	smg.SetRecoverPanics(false)
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("recovered %v - want the handler panic 'boom'", r)
+		}
+	}()
+	_ = smg.Change("Running")
+	t.Error("Change() returned - want it to panic")
+}
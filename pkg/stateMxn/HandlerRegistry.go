@@ -0,0 +1,119 @@
+package stateMxn
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type namedStateHandler struct {
+	name    string // empty for handlers added without name
+	handler StateHandler
+}
+
+// HandlerRecord is the record of an executed handler, stored in state.GetData()["handlers"] ([]HandlerRecord, in execution order)
+type HandlerRecord struct {
+	Phase        HandlerPhase
+	HandlerIndex int    // index of the handler in its phase, in execution order
+	Name         string // empty for handlers added without name (see State.AddHandlerExecNamed())
+	Duration     time.Duration
+	Err          error // the HandlerError returned by the handler, or nil
+}
+
+// Ex: "exec[0] 'fetchData' 1.2ms ok", "end[1] 3µs ERROR"
+func (hr HandlerRecord) String() string {
+	str := string(hr.Phase) + "[" + strconv.Itoa(hr.HandlerIndex) + "]"
+	if hr.Name != "" {
+		str += " '" + hr.Name + "'"
+	}
+	str += " " + hr.Duration.String()
+	if hr.Err != nil {
+		str += " ERROR"
+	} else {
+		str += " ok"
+	}
+	return str
+}
+
+/*
+HandlerRegistry keeps handlers by name, so they can be defined once and added (by name) into the states of many smachines:
+
+	registry := stateMxn.NewHandlerRegistry()
+	registry.Register("fetchData", fetchDataHandler)
+	...
+	err := registry.AddHandlerNamed(state, stateMxn.HandlerPhaseExec, "fetchData")
+
+Its safe for concurrent use
+*/
+type HandlerRegistry struct {
+	mu       sync.Mutex
+	handlers map[string]StateHandler
+}
+
+func NewHandlerRegistry() *HandlerRegistry {
+	return &HandlerRegistry{
+		handlers: make(map[string]StateHandler),
+	}
+}
+
+// Register adds handler with name into the registry. Fails if name is empty or already registered
+func (hreg *HandlerRegistry) Register(name string, handler StateHandler) error {
+	if name == "" {
+		return fmt.Errorf("handler name is empty")
+	}
+	if handler == nil {
+		return fmt.Errorf("handler '%s' is nil", name)
+	}
+	hreg.mu.Lock()
+	defer hreg.mu.Unlock()
+	if _, ok := hreg.handlers[name]; ok {
+		return fmt.Errorf("handler '%s' is already registered", name)
+	}
+	hreg.handlers[name] = handler
+	return nil
+}
+
+// Get returns the handler registered with name
+func (hreg *HandlerRegistry) Get(name string) (StateHandler, error) {
+	hreg.mu.Lock()
+	defer hreg.mu.Unlock()
+	handler, ok := hreg.handlers[name]
+	if !ok {
+		return nil, fmt.Errorf("handler '%s' is not registered", name)
+	}
+	return handler, nil
+}
+
+// GetNames returns the names of the registered handlers, sorted
+func (hreg *HandlerRegistry) GetNames() []string {
+	hreg.mu.Lock()
+	defer hreg.mu.Unlock()
+	names := make([]string, 0, len(hreg.handlers))
+	for name := range hreg.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AddHandlerNamed adds the handler registered with name into the handlers of phase of state (see State.AddHandlerBeginNamed(),
+// State.AddHandlerExecNamed() and State.AddHandlerEndNamed())
+func (hreg *HandlerRegistry) AddHandlerNamed(state StateIfc, phase HandlerPhase, name string) error {
+	handler, err := hreg.Get(name)
+	if err != nil {
+		return err
+	}
+	switch phase {
+	case HandlerPhaseBegin:
+		state.AddHandlerBeginNamed(name, handler)
+	case HandlerPhaseExec:
+		state.AddHandlerExecNamed(name, handler)
+	case HandlerPhaseEnd:
+		state.AddHandlerEndNamed(name, handler)
+	default:
+		return fmt.Errorf("handler '%s' can not be added into phase '%s' of state '%s'", name, phase, state.GetName())
+	}
+	return nil
+}
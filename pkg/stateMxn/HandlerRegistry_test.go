@@ -0,0 +1,62 @@
+package stateMxn
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestHandlerRegistryAddsNamedHandlers(t *testing.T) {
+	errFetch := errors.New("fetch fails")
+	registry := NewHandlerRegistry()
+	if err := registry.Register("prepare", func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register("fetchData", func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		return errFetch
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Register("fetchData", func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		return nil
+	}); err == nil {
+		t.Error("Register() of an already registered name did not fail")
+	}
+	if _, err := registry.Get("fetchData"); err != nil {
+		t.Errorf("Get(fetchData) = %v", err)
+	}
+
+	running := NewState("Running")
+	if err := registry.AddHandlerNamed(running, HandlerPhaseExec, "prepare"); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.AddHandlerNamed(running, HandlerPhaseExec, "fetchData"); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.AddHandlerNamed(running, HandlerPhaseExec, "bogus"); err == nil || !strings.Contains(err.Error(), "'bogus' is not registered") {
+		t.Errorf("AddHandlerNamed(bogus) = %v - want the not-registered error", err)
+	}
+	if err := registry.AddHandlerNamed(running, HandlerPhaseOnError, "prepare"); err == nil {
+		t.Error("AddHandlerNamed() into the onError phase did not fail")
+	}
+
+	smg, err := NewStateMxnGeneric("smx", map[string][]string{"Running": {"Finished"}}, map[string]StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = smg.Change("Running")
+	var he *HandlerError
+	if !errors.As(err, &he) || he.HandlerName != "fetchData" || he.HandlerIndex != 1 || !errors.Is(err, errFetch) {
+		t.Fatalf("Change() = %v - want the HandlerError of exec-handler[1] 'fetchData'", err)
+	}
+	if !strings.Contains(err.Error(), "'fetchData'") {
+		t.Errorf("HandlerError message %q - want the handler name", err.Error())
+	}
+
+	hRecords, _ := smg.GetCurrentState().GetData()["handlers"].([]HandlerRecord)
+	if len(hRecords) != 2 || hRecords[0].Name != "prepare" || hRecords[0].Err != nil || hRecords[1].Name != "fetchData" || hRecords[1].Err == nil {
+		t.Errorf("data[handlers] = %v - want the records of 'prepare' (ok) and 'fetchData' (ERROR)", hRecords)
+	}
+}
@@ -0,0 +1,149 @@
+package stateMxn
+
+/*
+InputMapping decides which inputs the next state receives, when the smachine changes into it. Its computed from the
+historyOfStates before the change (so, the last state of historyOfStates is the previous state).
+
+Set it per smachine with smg.SetInputMapping(), or per transition with smg.SetTransitionInputMapping() (which takes precedence).
+When not set, NewPassAllInputMapping() is used: the inputs are all the outputs of the previous state.
+
+The inputs of the initial state are set with smg.SetInitialInputs() (by default, empty)
+
+	smg.SetInputMapping(NewAccumulateInputMapping())
+	smg.SetTransitionInputMapping("RunningAlpha", "RunningBeta", NewPassOnlyInputMapping("fromAlpha"))
+	smg.SetTransitionInputMapping("RunningBeta", "FinishedOk", NewRenameInputMapping(map[string]string{"fromBeta": "result"}))
+
+The returned inputs are then copied into the next state with the smachine Copier (see Copier), so an InputMapping does not need
+to copy the values
+*/
+type InputMapping interface {
+	MapInputs(historyOfStates HistoryOfStates) StateInputs
+}
+
+// InputMappingFunc is a function that implements InputMapping
+type InputMappingFunc func(historyOfStates HistoryOfStates) StateInputs
+
+func (f InputMappingFunc) MapInputs(historyOfStates HistoryOfStates) StateInputs {
+	return f(historyOfStates)
+}
+
+// NewPassAllInputMapping passes all the outputs of the previous state (the default)
+func NewPassAllInputMapping() InputMapping {
+	return InputMappingFunc(func(historyOfStates HistoryOfStates) StateInputs {
+		inputs := make(StateInputs)
+		if len(historyOfStates) == 0 {
+			return inputs
+		}
+		for k, v := range historyOfStates[len(historyOfStates)-1].GetOutputs() {
+			inputs[k] = v
+		}
+		return inputs
+	})
+}
+
+// NewPassOnlyInputMapping passes only the listed keys of the outputs of the previous state (keys missing in the outputs are ignored)
+func NewPassOnlyInputMapping(keys ...string) InputMapping {
+	return InputMappingFunc(func(historyOfStates HistoryOfStates) StateInputs {
+		inputs := make(StateInputs)
+		if len(historyOfStates) == 0 {
+			return inputs
+		}
+		outputs := historyOfStates[len(historyOfStates)-1].GetOutputs()
+		for _, k := range keys {
+			if v, ok := outputs[k]; ok {
+				inputs[k] = v
+			}
+		}
+		return inputs
+	})
+}
+
+// NewRenameInputMapping passes all the outputs of the previous state, renaming the keys of renames: map[<outputKey>]<inputKey>
+func NewRenameInputMapping(renames map[string]string) InputMapping {
+	renamesCopy := make(map[string]string, len(renames))
+	for k, v := range renames {
+		renamesCopy[k] = v
+	}
+	return InputMappingFunc(func(historyOfStates HistoryOfStates) StateInputs {
+		inputs := make(StateInputs)
+		if len(historyOfStates) == 0 {
+			return inputs
+		}
+		for k, v := range historyOfStates[len(historyOfStates)-1].GetOutputs() {
+			if newK, ok := renamesCopy[k]; ok {
+				k = newK
+			}
+			inputs[k] = v
+		}
+		return inputs
+	})
+}
+
+// NewAccumulateInputMapping passes the outputs of all the previous states, merged in order (so when a key is output by
+// several states, the most recent value is passed)
+func NewAccumulateInputMapping() InputMapping {
+	return InputMappingFunc(func(historyOfStates HistoryOfStates) StateInputs {
+		inputs := make(StateInputs)
+		for _, state := range historyOfStates {
+			for k, v := range state.GetOutputs() {
+				inputs[k] = v
+			}
+		}
+		return inputs
+	})
+}
+
+// SetInputMapping sets the InputMapping used in every transition of the smachine (except those set with smg.SetTransitionInputMapping()).
+// When nil (the default), NewPassAllInputMapping() is used
+func (smg *StateMxnGeneric) SetInputMapping(inputMapping InputMapping) {
+	smg.inputMapping = inputMapping
+}
+
+// SetTransitionInputMapping sets the InputMapping used in the transition sourceStateName -> destinationStateName, which must
+// exist in the transitionsMap. When inputMapping is nil, the transition goes back to using the smachine InputMapping
+func (smg *StateMxnGeneric) SetTransitionInputMapping(sourceStateName string, destinationStateName string, inputMapping InputMapping) error {
+	if err := smg.verifyIfValidTransition(sourceStateName, destinationStateName); err != nil {
+		return err
+	}
+	if inputMapping == nil {
+		delete(smg.transitionInputMappings[sourceStateName], destinationStateName)
+		return nil
+	}
+	if smg.transitionInputMappings == nil {
+		smg.transitionInputMappings = make(map[string]map[string]InputMapping)
+	}
+	if smg.transitionInputMappings[sourceStateName] == nil {
+		smg.transitionInputMappings[sourceStateName] = make(map[string]InputMapping)
+	}
+	smg.transitionInputMappings[sourceStateName][destinationStateName] = inputMapping
+	return nil
+}
+
+// SetInitialInputs sets the inputs of the initial state (ie, the first state changed-into). When nil (the default), they are empty
+func (smg *StateMxnGeneric) SetInitialInputs(initialInputs StateInputs) {
+	smg.initialInputs = initialInputs
+}
+
+// Returns the inputs for nextStateName, according to the InputMapping of the transition from the current state
+func (smg *StateMxnGeneric) mapInputs(nextStateName string) StateInputs {
+	if smg.currentState == nil {
+		// initial state
+		inputs := make(StateInputs)
+		for k, v := range smg.initialInputs {
+			inputs[k] = v
+		}
+		return inputs
+	}
+	inputMapping := smg.transitionInputMappings[smg.currentState.GetName()][nextStateName]
+	if inputMapping == nil {
+		inputMapping = smg.inputMapping
+	}
+	if inputMapping == nil {
+		inputMapping = NewPassAllInputMapping()
+	}
+	inputs := inputMapping.MapInputs(smg.historyOfStates)
+	if inputs == nil {
+		inputs = make(StateInputs)
+	}
+	return inputs
+}
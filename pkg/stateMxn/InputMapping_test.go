@@ -0,0 +1,132 @@
+package stateMxn
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Returns a smachine Alpha -> Beta -> Gamma, where each state outputs outputsOf[<stateName>]
+func newInputMappingSmx(t *testing.T, outputsOf map[string]StateOutputs) *StateMxnGeneric {
+	t.Helper()
+	precreatedStates := make(map[string]StateIfc)
+	for _, stateName := range []string{"Alpha", "Beta", "Gamma"} {
+		stateOutputs := outputsOf[stateName]
+		state := NewState(stateName)
+		state.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+			for k, v := range stateOutputs {
+				outputs[k] = v
+			}
+			return nil
+		})
+		precreatedStates[stateName] = state
+	}
+	smg, err := NewStateMxnGeneric("inputMappingSmx", map[string][]string{
+		"Alpha": {"Beta"},
+		"Beta":  {"Gamma"},
+	}, precreatedStates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return smg
+}
+
+// Changes smg along stateNames, and returns the inputs received by the last state
+func changeAndGetInputs(t *testing.T, smg *StateMxnGeneric, stateNames ...string) StateInputs {
+	t.Helper()
+	for _, stateName := range stateNames {
+		if err := smg.Change(stateName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return smg.GetCurrentState().GetInputs()
+}
+
+func TestInputMappings(t *testing.T) {
+	outputsOf := map[string]StateOutputs{
+		"Alpha": {"fromAlpha": "a", "shared": "alpha"},
+		"Beta":  {"fromBeta": "b", "shared": "beta"},
+	}
+	for name, tc := range map[string]struct {
+		inputMapping InputMapping
+		wantInputs   StateInputs
+	}{
+		"default passes all":     {nil, StateInputs{"fromBeta": "b", "shared": "beta"}},
+		"pass only":              {NewPassOnlyInputMapping("fromBeta", "missing"), StateInputs{"fromBeta": "b"}},
+		"pass only missing keys": {NewPassOnlyInputMapping("missing"), StateInputs{}},
+		"rename":                 {NewRenameInputMapping(map[string]string{"fromBeta": "result", "missing": "other"}), StateInputs{"result": "b", "shared": "beta"}},
+		"accumulate":             {NewAccumulateInputMapping(), StateInputs{"fromAlpha": "a", "fromBeta": "b", "shared": "beta"}},
+		"nil inputs":             {InputMappingFunc(func(HistoryOfStates) StateInputs { return nil }), StateInputs{}},
+	} {
+		smg := newInputMappingSmx(t, outputsOf)
+		smg.SetInputMapping(tc.inputMapping)
+		if got := changeAndGetInputs(t, smg, "Alpha", "Beta", "Gamma"); !reflect.DeepEqual(got, tc.wantInputs) {
+			t.Errorf("%s: inputs of Gamma = %v - want %v", name, got, tc.wantInputs)
+		}
+	}
+}
+
+func TestTransitionInputMappingTakesPrecedence(t *testing.T) {
+	smg := newInputMappingSmx(t, map[string]StateOutputs{
+		"Alpha": {"fromAlpha": "a"},
+		"Beta":  {"fromBeta": "b"},
+	})
+	smg.SetInputMapping(NewAccumulateInputMapping())
+	if err := smg.SetTransitionInputMapping("Beta", "Gamma", NewRenameInputMapping(map[string]string{"fromBeta": "result"})); err != nil {
+		t.Fatal(err)
+	}
+	if err := smg.SetTransitionInputMapping("Alpha", "Gamma", NewPassAllInputMapping()); err == nil {
+		t.Error("SetTransitionInputMapping() of a transition not in the transitionsMap did not fail")
+	}
+	if got, want := changeAndGetInputs(t, smg, "Alpha", "Beta"), (StateInputs{"fromAlpha": "a"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("inputs of Beta = %v - want %v (the smachine InputMapping)", got, want)
+	}
+	if got, want := changeAndGetInputs(t, smg, "Gamma"), (StateInputs{"result": "b"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("inputs of Gamma = %v - want %v (the transition InputMapping)", got, want)
+	}
+
+	// a nil transition InputMapping goes back to the smachine InputMapping
+	smg = newInputMappingSmx(t, map[string]StateOutputs{"Alpha": {"fromAlpha": "a"}, "Beta": {"fromBeta": "b"}})
+	smg.SetInputMapping(NewAccumulateInputMapping())
+	_ = smg.SetTransitionInputMapping("Beta", "Gamma", NewPassOnlyInputMapping())
+	_ = smg.SetTransitionInputMapping("Beta", "Gamma", nil)
+	if got, want := changeAndGetInputs(t, smg, "Alpha", "Beta", "Gamma"), (StateInputs{"fromAlpha": "a", "fromBeta": "b"}); !reflect.DeepEqual(got, want) {
+		t.Errorf("inputs of Gamma = %v - want %v", got, want)
+	}
+}
+
+func TestInitialInputs(t *testing.T) {
+	smg := newInputMappingSmx(t, nil)
+	initialInputs := StateInputs{"config": "x"}
+	smg.SetInitialInputs(initialInputs)
+	got := changeAndGetInputs(t, smg, "Alpha")
+	if !reflect.DeepEqual(got, initialInputs) {
+		t.Errorf("inputs of the initial state = %v - want %v", got, initialInputs)
+	}
+	got["config"] = "modified"
+	if initialInputs["config"] != "x" {
+		t.Error("the inputs of the initial state share their map with smg.SetInitialInputs()")
+	}
+}
+
+func TestInputMappingIsCopiedWithTheCopier(t *testing.T) {
+	for name, tc := range map[string]struct {
+		copier     Copier
+		wantShared bool
+	}{
+		"default deep-copier": {nil, false},
+		"shallow copier":      {NewShallowCopier(), true},
+	} {
+		records := []string{"r1"}
+		smg := newInputMappingSmx(t, map[string]StateOutputs{"Alpha": {"records": records}})
+		smg.SetCopier(tc.copier)
+		smg.SetInputMapping(NewRenameInputMapping(map[string]string{"records": "renamedRecords"}))
+		inputs := changeAndGetInputs(t, smg, "Alpha", "Beta")
+
+		renamedRecords := inputs["renamedRecords"].([]string)
+		renamedRecords[0] = "modified"
+		alphaRecords := smg.GetHistoryOfStates()[0].GetOutputs()["records"].([]string)
+		if shared := alphaRecords[0] == "modified"; shared != tc.wantShared {
+			t.Errorf("%s: the renamed input shares its value with the output of Alpha = %v - want %v", name, shared, tc.wantShared)
+		}
+	}
+}
@@ -1,10 +1,12 @@
 package stateMxn
 
 import (
+	"fmt"
+	"reflect"
 	"regexp"
+	"runtime/debug"
+	"strings"
 	"time"
-
-	"github.com/mohae/deepcopy"
 )
 
 // NOTE:
@@ -16,18 +18,38 @@ type StateIfc interface {
 	GetInputs() StateInputs
 	GetOutputs() StateOutputs
 	GetError() error
+	GetErrors() []error
 	GetData() StateData
 	AddHandlerBegin(handler StateHandler)
 	AddHandlerExec(handler StateHandler)
 	AddHandlerEnd(handler StateHandler)
+	AddHandlerBeginNamed(name string, handler StateHandler)
+	AddHandlerExecNamed(name string, handler StateHandler)
+	AddHandlerEndNamed(name string, handler StateHandler)
+	GetHandlerNames(phase HandlerPhase) []string
+	AddHandlerOnError(handler StateErrorHandler)
+	Use(middleware ...StateHandlerMiddleware)
+	AddTimedTransition(after time.Duration, destinationStateName string)
+	AddDurableTimedTransition(after time.Duration, destinationStateName string)
+	GetTimedTransitions() []TimedTransition
+	SetEnclosedSmxDefinition(enclosedSmxDefinition EnclosedSmxDefinition)
+	GetEnclosedSmxDefinition() EnclosedSmxDefinition
+	RequireInput(key string, typ reflect.Type)
+	PromiseOutput(key string, typ reflect.Type)
+	GetInputsSchema() StateSchema
+	GetOutputsSchema() StateSchema
 	activate(smData StateMxnData, inputs StateInputs) (outputs StateOutputs, err error)
 	Is(stateNameRegexp string) (bool, error)
-	copy() StateIfc
+	copy(copier Copier) StateIfc
+	setSmx(smg *StateMxnGeneric)
 }
 
 // read inputs, write outputs, read/write data
 type StateHandler func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error
 
+// Like a StateHandler, but called when a phase of the state fails, with the failing phase and its error. See s.AddHandlerOnError()
+type StateErrorHandler func(phase HandlerPhase, err error, inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error
+
 type StateInputs map[string]interface{}
 
 type StateOutputs map[string]interface{}
@@ -48,8 +70,35 @@ type HistoryOfStates []StateIfc
 func (hos HistoryOfStates) DisplayStatesFlow() string {
 	var str string
 	for _, state := range hos {
-		str += state.GetName() + "\t[" + state.GetData()["timeElapsed"].(time.Duration).String() + "]"
-		if serr := state.GetError(); serr != nil {
+		// timeElapsed is not set in a state still activating (ex: when called from one of its handlers)
+		timeElapsedStr := "-"
+		if timeElapsed, ok := state.GetData()["timeElapsed"].(time.Duration); ok {
+			timeElapsedStr = timeElapsed.String()
+		}
+		str += state.GetName() + "\t[" + timeElapsedStr + "]"
+		// timings of the phases and handlers, when the state has handlers (see StateTimings)
+		if hRecords, ok := state.GetData()["handlers"].([]HandlerRecord); ok && len(hRecords) > 0 {
+			if timePhases, ok := state.GetData()["timePhases"].(map[HandlerPhase]time.Duration); ok {
+				str += "\t(phases: " + timePhasesString(timePhases) + ")"
+			}
+			var hStrs []string
+			for _, hRecord := range hRecords {
+				hStrs = append(hStrs, hRecord.String())
+			}
+			str += "\t(handlers: " + strings.Join(hStrs, ", ") + ")"
+		}
+		if ttf, ok := state.GetData()["firedTimedTransition"].(TimedTransitionFiring); ok {
+			str += "\t(" + ttf.String() + ")"
+		}
+		if taErrs, ok := state.GetData()["transitionActionErrors"].([]error); ok {
+			for _, taErr := range taErrs {
+				str += "\t!TRANSITION-ACTION-ERROR: " + taErr.Error()
+			}
+		}
+		if diff, ok := state.GetData()["smxDataDiff"].(StateMxnDataDiff); ok && !diff.IsEmpty() {
+			str += "\t{smx.data: " + diff.String() + "}"
+		}
+		for _, serr := range state.GetErrors() {
 			str += "\t!ERROR: " + serr.Error()
 		}
 		str += "\n"
@@ -68,6 +117,9 @@ func (hos HistoryOfStates) DisplayStatesFlow() string {
 
 /*
 Begin-handlers   >   Exec-handlers   >  End-handlers
+
+The End-handlers are always executed, whichever phase failed (like a defer). When a phase fails, the OnError-handlers are
+executed with the failing phase and its error
 */
 type State struct {
 	// single-word (without spaces)
@@ -78,26 +130,55 @@ type State struct {
 
 	// data is a map where handlers can store any data meaningfull for the state, and
 	// made publicly accesible with state.GetData()
-	// data["error"]     - stores error from any handler. Set with s.setError() and read with s.GetError()
+	// data["error"]     - stores the errors of the handlers, joined. Set with s.setError() and read with s.GetError()
+	// data["errors"]    - []error with all the errors of the handlers, in order. Read with s.GetErrors()
 	// --- timestamps ---
 	// data["timeStart"]
 	// data["timeEnd"]
 	// data["timeElapsed"]
+	// data["timePhases"] map[HandlerPhase]time.Duration - the duration of each phase. See StateTimings
 	//
-	// data["enclosedSmx"] *StateMxn  - if the state has an enclosed state machine, then it will be stored here
+	// data["enclosedSmx"] *StateMxn  - if the state has an enclosed state machine, then it will be stored here (a new one for each
+	//                                   activation of the state, see s.SetEnclosedSmxDefinition())
+	// data["smxDataDiff"] StateMxnDataDiff - the changes done into smx.data while the state was activated (see smg.SetRecordDataDiffs())
+	// data["transitionActionErrors"] []error - errors of the transition-actions (with TransitionActionRecord) run before this state
+	// data["firedTimedTransition"] TimedTransitionFiring - when the state was changed-into by a timed-transition of the previous state
+	// data["handlerInfo"] HandlerInfo - only while a handler is executing. See GetHandlerInfo()
+	// data["handlers"] []HandlerRecord - the name, duration and result of each executed handler, in execution order
 	data StateData
 
 	// handlers["begin"]
 	// handlers["exec"]
 	// handlers["end"]
-	handlers map[string][]StateHandler
+	// each with its name (empty for handlers added without name, see s.AddHandlerExecNamed())
+	handlers map[string][]namedStateHandler
+
+	// errorHandlers - executed when a phase fails. See s.AddHandlerOnError()
+	errorHandlers []StateErrorHandler
+
+	// middlewares - wrap the begin/exec/end-handlers. See s.Use() and StateHandlerMiddleware
+	middlewares []StateHandlerMiddleware
+
+	// inputsSchema and outputsSchema - verified on activation. See s.RequireInput() and s.PromiseOutput()
+	inputsSchema  StateSchema
+	outputsSchema StateSchema
+
+	// timedTransitions - armed by the smachine when it changes into this state. See s.AddTimedTransition()
+	timedTransitions []TimedTransition
+
+	// enclosedSmxDefinition - creates the data["enclosedSmx"] of each activation of the state. See s.SetEnclosedSmxDefinition()
+	enclosedSmxDefinition EnclosedSmxDefinition
+
+	// smx is the smachine that activates this state (set by smx before activation, nil while the state is a precreated-state)
+	// Its used to get smachine-wide settings, like the clock
+	smx *StateMxnGeneric
 }
 
 // inputs can be nil
 func NewState(name string) *State {
 	outputs := make(StateOutputs)
 	data := make(StateData)
-	handlers := make(map[string][]StateHandler)
+	handlers := make(map[string][]namedStateHandler)
 
 	newState := &State{
 		name:     name,
@@ -105,7 +186,6 @@ func NewState(name string) *State {
 		data:     data,
 		handlers: handlers,
 	}
-	newState.addDefaultHandlers()
 	return newState
 }
 func (s *State) GetName() string {
@@ -117,6 +197,13 @@ func (s *State) GetInputs() StateInputs {
 func (s *State) GetOutputs() StateOutputs {
 	return s.outputs
 }
+
+// GetErrors returns all the errors of the state, in the order they happened (ex: an exec-handler error followed by an end-handler error)
+func (s *State) GetErrors() []error {
+	return getErrorsFromData(s.data)
+}
+
+// GetError returns all the errors of the state joined (see errors.Join()), or nil
 func (s *State) GetError() error {
 	err, ok := s.GetData()["error"]
 	if ok {
@@ -131,62 +218,286 @@ func (s *State) GetData() StateData {
 
 // Appends a handler to the list of begin-handlers
 func (s *State) AddHandlerBegin(handler StateHandler) {
-	s.handlers["begin"] = append(s.handlers["begin"], handler)
+	s.AddHandlerBeginNamed("", handler)
 }
 
 // Appends a handler to the list of exec-handlers
 func (s *State) AddHandlerExec(handler StateHandler) {
-	s.handlers["exec"] = append(s.handlers["exec"], handler)
+	s.AddHandlerExecNamed("", handler)
 }
 
 // Preprends a handler to the list of end-handlers
 func (s *State) AddHandlerEnd(handler StateHandler) {
-	s.handlers["end"] = append([]StateHandler{handler}, s.handlers["end"]...)
+	s.AddHandlerEndNamed("", handler)
+}
+
+// Like s.AddHandlerBegin(), but the handler has a name - shown in the HandlerErrors, the data["handlers"] records and the
+// PlantUml diagrams. See also HandlerRegistry
+func (s *State) AddHandlerBeginNamed(name string, handler StateHandler) {
+	s.handlers["begin"] = append(s.handlers["begin"], namedStateHandler{name: name, handler: handler})
+}
+
+// Like s.AddHandlerExec(), but the handler has a name (see s.AddHandlerBeginNamed())
+func (s *State) AddHandlerExecNamed(name string, handler StateHandler) {
+	s.handlers["exec"] = append(s.handlers["exec"], namedStateHandler{name: name, handler: handler})
+}
+
+// Like s.AddHandlerEnd(), but the handler has a name (see s.AddHandlerBeginNamed())
+func (s *State) AddHandlerEndNamed(name string, handler StateHandler) {
+	s.handlers["end"] = append([]namedStateHandler{{name: name, handler: handler}}, s.handlers["end"]...)
+}
+
+// GetHandlerNames returns the names of the handlers of phase, in execution order ("" for the handlers added without name)
+func (s *State) GetHandlerNames(phase HandlerPhase) []string {
+	var names []string
+	for _, nh := range s.handlers[string(phase)] {
+		names = append(names, nh.name)
+	}
+	return names
+}
+
+// Appends a handler to the list of onError-handlers, which are executed each time a phase fails: after the failing begin-handler
+// or exec-handler (before the end-handlers), and after each failing end-handler. They get the failing phase and its error.
+// An error of an onError-handler is stored in the state errors (with phase HandlerPhaseOnError), but does not trigger the
+// onError-handlers again
+func (s *State) AddHandlerOnError(handler StateErrorHandler) {
+	s.errorHandlers = append(s.errorHandlers, handler)
+}
+
+// Declares that the inputs must contain key, with a value of type typ (or any type, if typ is nil). See StateSchema
+func (s *State) RequireInput(key string, typ reflect.Type) {
+	if s.inputsSchema == nil {
+		s.inputsSchema = make(StateSchema)
+	}
+	s.inputsSchema[key] = typ
+}
+
+// Declares that the outputs will contain key, with a value of type typ (or any type, if typ is nil). See StateSchema
+func (s *State) PromiseOutput(key string, typ reflect.Type) {
+	if s.outputsSchema == nil {
+		s.outputsSchema = make(StateSchema)
+	}
+	s.outputsSchema[key] = typ
+}
+
+func (s *State) GetInputsSchema() StateSchema {
+	return s.inputsSchema
+}
+
+func (s *State) GetOutputsSchema() StateSchema {
+	return s.outputsSchema
+}
+
+// Declares that, after the state is changed-into, if the duration after elapses (measured with the smachine clock) and the
+// smachine is still in this state, then the smachine will change to destinationStateName
+func (s *State) AddTimedTransition(after time.Duration, destinationStateName string) {
+	s.timedTransitions = append(s.timedTransitions, TimedTransition{After: after, DestinationStateName: destinationStateName})
+}
+
+// Like s.AddTimedTransition(), but the timer is durable: its deadline is part of the smachine snapshot, and when the smachine
+// is restored (ex: after a process restart) the timer is re-armed - or fired immediately if its deadline already passed.
+// See StateMxnSnapshot
+func (s *State) AddDurableTimedTransition(after time.Duration, destinationStateName string) {
+	s.timedTransitions = append(s.timedTransitions, TimedTransition{After: after, DestinationStateName: destinationStateName, Durable: true})
+}
+
+func (s *State) GetTimedTransitions() []TimedTransition {
+	return s.timedTransitions
+}
+
+// SetEnclosedSmxDefinition makes the state enclose a smachine: each time the state is activated, a new enclosedSmx is created
+// with enclosedSmxDefinition and stored in its data["enclosedSmx"], for its handlers to progress it. See EnclosedSmxDefinition
+func (s *State) SetEnclosedSmxDefinition(enclosedSmxDefinition EnclosedSmxDefinition) {
+	s.enclosedSmxDefinition = enclosedSmxDefinition
+}
+
+func (s *State) GetEnclosedSmxDefinition() EnclosedSmxDefinition {
+	return s.enclosedSmxDefinition
 }
 
 // Executes all handlers in the order: begin-handlers, exec-handlers, end-handlers
-// If there is an error in any begin-handler, it will return it and not execute the exec-handlers nor end-handlers
-// If there is an error in any exec-handler, then it will still execute the end-handlers and then return the error
+// If there is an error in any begin-handler, it will not execute the exec-handlers, but will still execute the end-handlers
+// If there is an error in any exec-handler, it will still execute the end-handlers
+// The end-handlers are always all executed, even if some of them fail (like a defer)
+// Each time a phase fails, the onError-handlers are executed with the failing phase and error (see s.AddHandlerOnError())
+// All the errors are stored in the state (see s.GetErrors()), and the first one is returned.
+// The errors of the handlers are returned wrapped in a HandlerError
+//
+// The inputs are verified against the inputs-schema before the begin-handlers (failing like a begin-handler), and
+// the outputs are verified against the outputs-schema after the exec-handlers (failing like an exec-handler). See StateSchema
+//
+// The timestamps data["timeStart"], data["timeEnd"] and data["timeElapsed"] are taken from the clock of the smachine,
+// just before the first begin-handler and just after the last end-handler (always set, even if some phase failed).
+// The duration of each phase is stored in data["timePhases"], and of each handler in data["handlers"] (see StateTimings)
 func (s *State) activate(smData StateMxnData, inputs StateInputs) (outputs StateOutputs, err error) {
-	// inputs deepcopied to assure that the state will not modify the inputs
-	s.inputs = deepcopy.Copy(inputs).(StateInputs)
+	// inputs copied (by default deepcopied) to assure that the state will not modify the inputs
+	s.inputs = StateInputs(s.getInputsCopier().Copy(inputs))
+
+	clock := s.getClock()
+	s.data["timeStart"] = clock.Now()
+	timePhases := make(map[HandlerPhase]time.Duration)
+	s.data["timePhases"] = timePhases
+
+	// Stores the error of the failed phase, and executes the onError-handlers
+	var firstErr error
+	failed := func(phase HandlerPhase, err error) {
+		s.setError(err)
+		if firstErr == nil {
+			firstErr = err
+		}
+		for i, errorHandler := range s.errorHandlers {
+			errorHandler := errorHandler
+			handler := func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+				return errorHandler(phase, err, inputs, outputs, stateData, smachineData)
+			}
+			if ehErr := s.runHandler(HandlerPhaseOnError, i, "", handler, smData); ehErr != nil {
+				s.setError(ehErr)
+			}
+		}
+	}
 
-	// Executes all begin-handlers
-	for _, handler := range s.handlers["begin"] {
-		err := handler(s.inputs, s.outputs, s.data, smData)
-		if err != nil {
-			s.setError(err)
-			return nil, err
+	// begin-phase
+	{
+		phaseStart := clock.Now()
+		// Verifies the inputs-schema
+		if err := s.inputsSchema.validate(s.inputs, "inputs", s.name); err != nil {
+			failed(HandlerPhaseBegin, err)
 		}
+
+		// Executes all begin-handlers
+		if firstErr == nil {
+			for i, nh := range s.handlers["begin"] {
+				if err := s.runHandler(HandlerPhaseBegin, i, nh.name, nh.handler, smData); err != nil {
+					failed(HandlerPhaseBegin, err)
+					break
+				}
+			}
+		}
+		timePhases[HandlerPhaseBegin] = clock.Now().Sub(phaseStart)
 	}
 
-	// Executes all exec-handlers
-	var execErr error
-	for _, handler := range s.handlers["exec"] {
-		execErr = handler(s.inputs, s.outputs, s.data, smData)
-		if execErr != nil {
-			s.setError(execErr)
-			break
+	// exec-phase (if the begin-phase did not fail)
+	if firstErr == nil {
+		phaseStart := clock.Now()
+		// Executes all exec-handlers
+		for i, nh := range s.handlers["exec"] {
+			if err := s.runHandler(HandlerPhaseExec, i, nh.name, nh.handler, smData); err != nil {
+				failed(HandlerPhaseExec, err)
+				break
+			}
 		}
+
+		// Verifies the outputs-schema (if the exec-handlers did not fail)
+		if firstErr == nil {
+			if err := s.outputsSchema.validate(s.outputs, "outputs", s.name); err != nil {
+				failed(HandlerPhaseExec, err)
+			}
+		}
+		timePhases[HandlerPhaseExec] = clock.Now().Sub(phaseStart)
 	}
 
-	// Executes all end-handlers
-	for _, handler := range s.handlers["end"] {
-		err := handler(s.inputs, s.outputs, s.data, smData)
-		if err != nil {
-			s.setError(err)
-			return nil, err
+	// end-phase
+	{
+		phaseStart := clock.Now()
+		// Executes all end-handlers (always, and all of them)
+		for i, nh := range s.handlers["end"] {
+			if err := s.runHandler(HandlerPhaseEnd, i, nh.name, nh.handler, smData); err != nil {
+				failed(HandlerPhaseEnd, err)
+			}
 		}
+		timePhases[HandlerPhaseEnd] = clock.Now().Sub(phaseStart)
 	}
-	if execErr != nil {
-		return nil, execErr
+	s.data["timeEnd"] = clock.Now()
+	s.data["timeElapsed"] = s.data["timeEnd"].(time.Time).Sub(s.data["timeStart"].(time.Time))
+	if firstErr != nil {
+		return nil, firstErr
 	}
 
 	return s.outputs, nil
 }
 
+// Calls handler (wrapped by the middlewares, see StateHandlerMiddleware), in strict-mode if the smachine has it enabled.
+// A returned error is wrapped in a HandlerError
+// A HandlerRecord of the execution is appended to data["handlers"]
+//
+// Unless disabled with smg.SetRecoverPanics(false), a panic in the handler is recovered and returned as a HandlerError
+// with Panicked=true and the stack trace
+func (s *State) runHandler(phase HandlerPhase, index int, name string, handler StateHandler, smData StateMxnData) (herr error) {
+	clock := s.getClock()
+	timeStart := clock.Now()
+	defer func() {
+		hRecords, _ := s.data["handlers"].([]HandlerRecord)
+		s.data["handlers"] = append(hRecords, HandlerRecord{
+			Phase:        phase,
+			HandlerIndex: index,
+			Name:         name,
+			Duration:     clock.Now().Sub(timeStart),
+			Err:          herr,
+		})
+	}()
+
+	var err error
+	panicked := false
+	var panicValue interface{}
+	var panicStack []byte
+	if phase != HandlerPhaseOnError {
+		handler = s.wrapWithMiddlewares(phase, index, name, handler)
+	}
+	func() {
+		if s.smx == nil || s.smx.recoverPanics {
+			defer func() {
+				if panicked {
+					panicValue = recover()
+					panicStack = debug.Stack()
+				}
+			}()
+		}
+		panicked = true
+		if s.smx != nil && s.smx.strictMode {
+			err = s.runHandlerStrict(handler, smData)
+		} else {
+			err = handler(s.inputs, s.outputs, s.data, smData)
+		}
+		panicked = false
+	}()
+	if panicked {
+		if panicErr, ok := panicValue.(error); ok {
+			err = fmt.Errorf("panic: %w", panicErr)
+		} else {
+			err = fmt.Errorf("panic: %v", panicValue)
+		}
+		he := s.newHandlerError(phase, index, name, err)
+		he.Panicked = true
+		he.PanicValue = panicValue
+		he.Stack = panicStack
+		return he
+	}
+	if err != nil {
+		return s.newHandlerError(phase, index, name, err)
+	}
+	return nil
+}
+
+func (s *State) newHandlerError(phase HandlerPhase, index int, name string, err error) *HandlerError {
+	he := &HandlerError{
+		StateName:    s.name,
+		Phase:        phase,
+		HandlerIndex: index,
+		HandlerName:  name,
+		Err:          err,
+	}
+	if s.smx != nil {
+		he.SmxName = s.smx.GetName()
+		he.NestingPath = append(s.smx.getNestingPath(), s.name)
+	} else {
+		he.NestingPath = []string{s.name}
+	}
+	return he
+}
+
+// Appends err to the errors of the state (see s.GetErrors()), and sets data["error"] with all of them joined
 func (s *State) setError(err error) {
-	s.data["error"] = err
+	setErrorInData(s.data, err)
 }
 
 // Is returns true if the state name matches the given regexp
@@ -203,51 +514,68 @@ func (s *State) Is(stateNameRegexp string) (bool, error) {
 	return regexp.MatchString(stateNameRegexp, s.name)
 }
 
-func (s *State) copy() StateIfc {
+// Returns a copy of the state, where inputs, outputs and data are copied with copier, except data["enclosedSmx"] which is not
+// copied (each activation of the state creates its own enclosedSmx, see s.SetEnclosedSmxDefinition())
+func (s *State) copy(copier Copier) StateIfc {
 	// NOTE: deepcopy libs like https://github.com/barkimedes/go-deepcopy or https://github.com/mohae/deepcopy
 	//       dont copy unexported fields - so we need to define our own deepcopy() method
 	// 	     for the type, in the package where the type is defined
 
-	// Creates new map, copies the basic-types but does not deep-copy pointers-to-types (like maps, slices, *structs)
-	copyMapIfc := func(m map[string]interface{}) map[string]interface{} {
-		mCopy := make(map[string]interface{})
+	copyMapSliceStateHandler := func(m map[string][]namedStateHandler) map[string][]namedStateHandler {
+		mCopy := make(map[string][]namedStateHandler)
 		for k, v := range m {
-			mCopy[k] = v
+			vCopy := make([]namedStateHandler, len(v))
+			copy(vCopy, v)
+			mCopy[k] = vCopy
 		}
 		return mCopy
 	}
 
-	copyMapSliceStateHandler := func(m map[string][]StateHandler) map[string][]StateHandler {
-		mCopy := make(map[string][]StateHandler)
-		for k, v := range m {
-			vCopy := make([]StateHandler, len(v))
-			copy(vCopy, v)
-			mCopy[k] = vCopy
+	data := s.data
+	if _, ok := data["enclosedSmx"]; ok {
+		data = make(StateData, len(s.data))
+		for k, v := range s.data {
+			if k != "enclosedSmx" {
+				data[k] = v
+			}
 		}
-		return mCopy
 	}
 
 	// all truct fields, both exported and unexported, need to be copied here
 	stateCopy := &State{
-		name:     s.name,
-		inputs:   copyMapIfc(s.inputs),                 // deepcopy.Copy(s.inputs).(StateInputs),
-		outputs:  copyMapIfc(s.outputs),                // deepcopy.Copy(s.outputs).(StateOutputs),
-		data:     copyMapIfc(s.data),                   // deepcopy.Copy(s.data).(StateData),
-		handlers: copyMapSliceStateHandler(s.handlers), // deepcopy.Copy(s.handlers).(map[string][]StateHandler),
+		name:             s.name,
+		inputs:           copier.Copy(s.inputs),
+		outputs:          copier.Copy(s.outputs),
+		data:             copier.Copy(data),
+		handlers:         copyMapSliceStateHandler(s.handlers), // deepcopy.Copy(s.handlers).(map[string][]namedStateHandler),
+		errorHandlers:    append([]StateErrorHandler{}, s.errorHandlers...),
+		middlewares:      append([]StateHandlerMiddleware{}, s.middlewares...),
+		inputsSchema:     s.inputsSchema.copy(),
+		outputsSchema:    s.outputsSchema.copy(),
+		timedTransitions: append([]TimedTransition{}, s.timedTransitions...),
+
+		enclosedSmxDefinition: s.enclosedSmxDefinition,
+		smx:                   s.smx,
 	}
 	return stateCopy
 }
 
-func (s *State) addDefaultHandlers() {
-	timeStartHandlerBegin := func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
-		stateData["timeStart"] = time.Now()
-		return nil
+func (s *State) setSmx(smg *StateMxnGeneric) {
+	s.smx = smg
+}
+
+// Returns the Copier of the smachine of the state, or the deep-copier if not set
+func (s *State) getInputsCopier() Copier {
+	if s.smx == nil || s.smx.GetCopier() == nil {
+		return NewDeepCopier()
 	}
-	timeEndHandlerEnd := func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
-		stateData["timeEnd"] = time.Now()
-		stateData["timeElapsed"] = stateData["timeEnd"].(time.Time).Sub(stateData["timeStart"].(time.Time))
-		return nil
+	return s.smx.GetCopier()
+}
+
+// Returns the clock of the smachine of the state, or the real clock if the state is not (yet) in a smachine
+func (s *State) getClock() Clock {
+	if s.smx == nil {
+		return NewRealClock()
 	}
-	s.AddHandlerBegin(timeStartHandlerBegin)
-	s.AddHandlerEnd(timeEndHandlerEnd)
+	return s.smx.GetClock()
 }
@@ -20,6 +20,8 @@ func NewStateEnclosingSmxSimpleflow(stateName string, smxInnerSf *StateMxnSimple
 	return se
 }
 
+// GetEnclosedSmx returns the smxInnerSf given to NewStateEnclosingSmxSimpleflow(). Its only the template of the enclosedSmx of each
+// activation of the state (see EnclosedSmxDefinition): the enclosedSmx that runs is in the data["enclosedSmx"] of the activated state
 func (se *StateEnclosingSmxSimpleflow) GetEnclosedSmx() *StateMxnSimpleflow {
 	return se.GetData()["enclosedSmx"].(*StateMxnSimpleflow)
 }
@@ -0,0 +1,142 @@
+package stateMxn
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+StateMxnDataDiff is the difference of smx.data before and after the activation of a state (ie, what its handlers wrote
+into the smachine-data). Its stored in state.data["smxDataDiff"] of each state of the historyOfStates (unless disabled with
+smg.SetRecordDataDiffs(false)), and shown in DisplayStatesFlow() and GetPlantUml()
+
+Only the keys of smx.data are compared, and nothing is copied: pointers, maps, slices, channels and funcs are compared by identity
+(a slice also by its length), and the other values by equality. So a key is changed when a handler assigns it a different value,
+but not when a handler modifies in-place the map/slice/struct it points to
+*/
+type StateMxnDataDiff struct {
+	Added   map[string]interface{}
+	Changed map[string]StateMxnDataChange
+	Removed map[string]interface{}
+}
+
+// StateMxnDataChange is the old and new value of a changed key of smx.data
+type StateMxnDataChange struct {
+	Old interface{}
+	New interface{}
+}
+
+// Returns a copy of smData (only of the map, the values are shared) to be later compared with diffStateMxnData()
+func snapshotStateMxnData(smData StateMxnData) StateMxnData {
+	before := make(StateMxnData, len(smData))
+	for k, v := range smData {
+		before[k] = v
+	}
+	return before
+}
+
+// Returns an empty StateMxnDataDiff
+func newStateMxnDataDiff() StateMxnDataDiff {
+	return StateMxnDataDiff{
+		Added:   make(map[string]interface{}),
+		Changed: make(map[string]StateMxnDataChange),
+		Removed: make(map[string]interface{}),
+	}
+}
+
+// Returns the diff from before (see snapshotStateMxnData()) to after
+func diffStateMxnData(before StateMxnData, after StateMxnData) StateMxnDataDiff {
+	diff := newStateMxnDataDiff()
+	for k, newV := range after {
+		oldV, ok := before[k]
+		if !ok {
+			diff.Added[k] = newV
+		} else if !sameValue(oldV, newV) {
+			diff.Changed[k] = StateMxnDataChange{Old: oldV, New: newV}
+		}
+	}
+	for k, oldV := range before {
+		if _, ok := after[k]; !ok {
+			diff.Removed[k] = oldV
+		}
+	}
+	return diff
+}
+
+// Returns true if a and b are the same value: pointers, maps, slices (with the same length), channels and funcs are compared by
+// identity, comparable values with ==, and other values (ex: arrays or structs containing slices) with reflect.DeepEqual()
+func sameValue(a interface{}, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+	switch va.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		return va.Pointer() == vb.Pointer()
+	case reflect.Slice:
+		return va.Pointer() == vb.Pointer() && va.Len() == vb.Len()
+	}
+	// va.Comparable() also checks the dynamic values of interfaces inside va (for which == would panic if not comparable)
+	if va.Comparable() && vb.Comparable() {
+		return va.Equal(vb)
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// SetRecordDataDiffs enables (the default) or disables the recording of the StateMxnDataDiff of each state, in its
+// state.data["smxDataDiff"], and of the last state that wrote each key of smx.data (see smg.GetDataKeyWriter())
+func (smg *StateMxnGeneric) SetRecordDataDiffs(record bool) {
+	smg.recordDataDiffs = record
+}
+
+// IsEmpty returns true if smx.data was not modified
+func (diff StateMxnDataDiff) IsEmpty() bool {
+	return len(diff.Added) == 0 && len(diff.Changed) == 0 && len(diff.Removed) == 0
+}
+
+// String returns the sorted keys, with a prefix: "+" added, "~" changed, "-" removed. Ex: "+count=1, ~status: running->done, -tmp"
+func (diff StateMxnDataDiff) String() string {
+	var strs []string
+	for _, k := range sortedMapKeys(diff.Added) {
+		strs = append(strs, "+"+k+"="+formatDiffValue(diff.Added[k]))
+	}
+	changedKeys := make([]string, 0, len(diff.Changed))
+	for k := range diff.Changed {
+		changedKeys = append(changedKeys, k)
+	}
+	sort.Strings(changedKeys)
+	for _, k := range changedKeys {
+		strs = append(strs, "~"+k+": "+formatDiffValue(diff.Changed[k].Old)+"->"+formatDiffValue(diff.Changed[k].New))
+	}
+	for _, k := range sortedMapKeys(diff.Removed) {
+		strs = append(strs, "-"+k)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// Basic types are shown with their value, and other types only with their type
+func formatDiffValue(v interface{}) string {
+	switch v := v.(type) {
+	case string, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64, complex64, complex128, bool, time.Duration:
+		return fmt.Sprintf("%v", v)
+	case error:
+		return v.Error()
+	default:
+		return fmt.Sprintf("(%T)", v)
+	}
+}
+
+func sortedMapKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,87 @@
+package stateMxn
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+type structWithInterface struct {
+	v interface{}
+}
+
+func TestSameValue(t *testing.T) {
+	buf := &bytes.Buffer{}
+	slice := []int{1, 2, 3}
+	m := map[string]int{"a": 1}
+	err := errors.New("err")
+	cyclic := map[string]interface{}{}
+	cyclic["self"] = cyclic
+
+	for name, tc := range map[string]struct {
+		a, b interface{}
+		want bool
+	}{
+		"same pointer":                   {buf, buf, true},
+		"other pointer":                  {buf, &bytes.Buffer{}, false},
+		"same map":                       {m, m, true},
+		"equal map, other identity":      {m, map[string]int{"a": 1}, false},
+		"same slice":                     {slice, slice, true},
+		"shorter slice":                  {slice, slice[:2], false},
+		"same cyclic map":                {cyclic, cyclic, true},
+		"equal strings":                  {"x", "x", true},
+		"different types":                {1, int64(1), false},
+		"same error":                     {err, err, true},
+		"nil and nil":                    {nil, nil, true},
+		"nil and value":                  {nil, 0, false},
+		"struct with uncomparable field": {structWithInterface{slice}, structWithInterface{slice}, true},
+		"array of slices":                {[1][]int{slice}, [1][]int{slice}, true},
+	} {
+		if got := sameValue(tc.a, tc.b); got != tc.want {
+			t.Errorf("%s: sameValue() = %v - want %v", name, got, tc.want)
+		}
+	}
+}
+
+func TestDataDiffsAreRecordedByDefault(t *testing.T) {
+	buf := bytes.NewBufferString("user data")
+	running := NewState("Running")
+	running.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		smData["buf"].(*bytes.Buffer).WriteString(", more")
+		smData["count"] = 1
+		return nil
+	})
+	newSmx := func() *StateMxnGeneric {
+		smg, err := NewStateMxnGeneric("smx", map[string][]string{"Init": {"Running"}}, map[string]StateIfc{"Running": running})
+		if err != nil {
+			t.Fatal(err)
+		}
+		smg.GetData()["buf"] = buf
+		return smg
+	}
+
+	smg := newSmx()
+	smg.SetRecordDataDiffs(false)
+	_ = smg.Change("Init")
+	_ = smg.Change("Running")
+	if _, ok := smg.GetCurrentState().GetData()["smxDataDiff"]; ok {
+		t.Error("smxDataDiff recorded after smg.SetRecordDataDiffs(false)")
+	}
+	if smg.GetDataKeyWriter("count") != "" {
+		t.Errorf("writer of count recorded after smg.SetRecordDataDiffs(false): %q", smg.GetDataKeyWriter("count"))
+	}
+
+	smg = newSmx()
+	_ = smg.Change("Init")
+	_ = smg.Change("Running")
+	diff := smg.GetCurrentState().GetData()["smxDataDiff"].(StateMxnDataDiff)
+	if diff.String() != "+count=1" {
+		t.Errorf("smxDataDiff = %q - want \"+count=1\" (buf is the same pointer)", diff)
+	}
+	if smg.GetDataKeyWriter("count") != "Running" || smg.GetDataKeyWriter("buf") != "" {
+		t.Errorf("writers: count=%q buf=%q", smg.GetDataKeyWriter("count"), smg.GetDataKeyWriter("buf"))
+	}
+	if buf.String() != "user data, more, more" {
+		t.Errorf("buf = %q", buf.String())
+	}
+}
@@ -0,0 +1,291 @@
+package stateMxn
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+/*
+StateMxnDefinition is the immutable definition of a smachine: its smxName, transitionsMap and precreatedStates.
+
+Its built and validated once with NewStateMxnDefinition(), and then many smachine instances can be created cheaply from it
+with def.NewInstance(id). The instances share (read-only) the transitionsMap and precreatedStates of the definition, and each
+activated state is always a copy of its precreated-state, so the definition is safe to share across goroutines.
+
+NOTE: the instances never share an enclosedSmx: each activation of a precreated-state with an enclosedSmx creates a new one (see
+EnclosedSmxDefinition). A precreated-state with a smachine directly in its data["enclosedSmx"] gets it as the template of the
+enclosedSmx of each activation
+*/
+type StateMxnDefinition struct {
+	smxName string
+	// rawTransitionsMap - as given by the user, possibly with patterns. transitionsMap - with the patterns expanded
+	rawTransitionsMap map[string][]string
+	transitionsMap    map[string][]string
+	precreatedStates  map[string]StateIfc
+
+	// initialStateNames, finalStateNames and failedFinalStateNames - declared in StateMxnDefinitionOpts (nil when not declared)
+	initialStateNames     []string
+	finalStateNames       []string
+	failedFinalStateNames []string
+}
+
+// StateMxnDefinitionOpts are the options of NewStateMxnDefinitionWithOpts(). opts can be nil
+type StateMxnDefinitionOpts struct {
+	// InitialStates are the states that can be the initial state (ie, the first smg.Change()).
+	// When empty, any state can be the initial state
+	InitialStates []string
+
+	// FinalStates are the states where the smachine completes (see smg.Status()), and out of which smg.Change() is rejected
+	// with a FinalStateError. They must have no transitions, and all states without transitions must be declared as final.
+	// When empty, the final states are the states without transitions
+	FinalStates []string
+
+	// FailedFinalStates are the final states where the smachine failed (ex: "FinishedNok"), see StatusFailed. They must be final states.
+	// When empty, the smachine failed if the final state reached has errors
+	FailedFinalStates []string
+}
+
+// NewStateMxnDefinition copies transitionsMap and precreatedStates (which can be nil), so later modifications to them do not affect
+// the definition. Any state of the transitionsMap without a precreated-state gets a new empty state (see NewState())
+//
+// The transitionsMap can contain patterns ("*" and regexps) as sources and destinations, see TransitionsMapPatterns.go
+//
+// Validations:
+//   - transitionsMap is not empty, and has no empty state names
+//   - the patterns of the transitionsMap are valid regexps, and match some state
+//   - each precreatedStates[<name>] is a state with that same name, which is in the transitionsMap
+//   - the smachine in the data["enclosedSmx"] of a precreated-state (if any) can be used as template (see EnclosedSmxDefinition)
+func NewStateMxnDefinition(smxName string, transitionsMap map[string][]string, precreatedStates map[string]StateIfc) (*StateMxnDefinition, error) {
+	return NewStateMxnDefinitionWithOpts(smxName, transitionsMap, precreatedStates, nil)
+}
+
+// NewStateMxnDefinitionWithOpts is like NewStateMxnDefinition(), but also declaring the initial and final states (see StateMxnDefinitionOpts).
+// opts can be nil
+//
+// Additional validations:
+//   - the declared initial and final states are in the transitionsMap
+//   - the declared final states have no transitions, and all states without transitions are declared final
+//   - the declared failed final states are final states
+func NewStateMxnDefinitionWithOpts(smxName string, transitionsMap map[string][]string, precreatedStates map[string]StateIfc, opts *StateMxnDefinitionOpts) (*StateMxnDefinition, error) {
+	if opts == nil {
+		opts = &StateMxnDefinitionOpts{}
+	}
+	def := &StateMxnDefinition{
+		smxName:           smxName,
+		rawTransitionsMap: make(map[string][]string),
+		precreatedStates:  make(map[string]StateIfc),
+	}
+
+	// Define def.transitionsMap, as a copy of transitionsMap
+	if len(transitionsMap) == 0 {
+		return nil, fmt.Errorf("smachine '%s': transitionsMap is empty", smxName)
+	}
+	for source, destinations := range transitionsMap {
+		def.rawTransitionsMap[source] = append([]string{}, destinations...)
+	}
+	// Expand the patterns of the transitionsMap (see TransitionsMapPatterns.go)
+	{
+		expanded, err := expandTransitionsMap(def.rawTransitionsMap)
+		if err != nil {
+			return nil, fmt.Errorf("smachine '%s': invalid transitionsMap: %w", smxName, err)
+		}
+		def.transitionsMap = expanded
+	}
+	stateNames := allStatenames(def.transitionsMap)
+	if containsString(stateNames, "") {
+		return nil, fmt.Errorf("smachine '%s': transitionsMap contains an empty state name", smxName)
+	}
+
+	// Define def.initialStateNames and def.finalStateNames
+	{
+		for _, name := range opts.InitialStates {
+			if !containsString(stateNames, name) {
+				return nil, fmt.Errorf("smachine '%s': declared initial state '%s' is not in the transitionsMap", smxName, name)
+			}
+		}
+		for _, name := range opts.FinalStates {
+			if !containsString(stateNames, name) {
+				return nil, fmt.Errorf("smachine '%s': declared final state '%s' is not in the transitionsMap", smxName, name)
+			}
+			if !isFinalStateInTransitionsMap(def.transitionsMap, name) {
+				return nil, fmt.Errorf("smachine '%s': declared final state '%s' has transitions %v", smxName, name, def.transitionsMap[name])
+			}
+		}
+		if len(opts.FinalStates) > 0 {
+			for _, name := range stateNames {
+				if isFinalStateInTransitionsMap(def.transitionsMap, name) && !containsString(opts.FinalStates, name) {
+					return nil, fmt.Errorf("smachine '%s': state '%s' has no transitions, but is not declared as a final state", smxName, name)
+				}
+			}
+		}
+		for _, name := range opts.FailedFinalStates {
+			if !containsString(stateNames, name) {
+				return nil, fmt.Errorf("smachine '%s': declared failed final state '%s' is not in the transitionsMap", smxName, name)
+			}
+			if !isFinalStateInTransitionsMap(def.transitionsMap, name) {
+				return nil, fmt.Errorf("smachine '%s': declared failed final state '%s' is not a final state", smxName, name)
+			}
+		}
+		if len(opts.InitialStates) > 0 {
+			def.initialStateNames = append([]string{}, opts.InitialStates...)
+		}
+		if len(opts.FinalStates) > 0 {
+			def.finalStateNames = append([]string{}, opts.FinalStates...)
+		}
+		if len(opts.FailedFinalStates) > 0 {
+			def.failedFinalStateNames = append([]string{}, opts.FailedFinalStates...)
+		}
+	}
+
+	// Define def.precreatedStates, with copies of precreatedStates, and new states for the other states of the transitionsMap
+	for name, state := range precreatedStates {
+		if state == nil || state.GetName() != name {
+			return nil, fmt.Errorf("smachine '%s': precreatedStates['%s'] is not a state named '%s'", smxName, name, name)
+		}
+		if !containsString(stateNames, name) {
+			return nil, fmt.Errorf("smachine '%s': precreated-state '%s' is not in the transitionsMap", smxName, name)
+		}
+		stateCopy := state.copy(NewDeepCopier())
+		if eSmx, ok := state.GetData()["enclosedSmx"].(StateMxnIfc); ok && stateCopy.GetEnclosedSmxDefinition() == nil {
+			eSmxTemplate, err := newEnclosedSmxTemplate(eSmx)
+			if err != nil {
+				return nil, fmt.Errorf("smachine '%s': precreated-state '%s': %w", smxName, name, err)
+			}
+			stateCopy.SetEnclosedSmxDefinition(eSmxTemplate)
+		}
+		def.precreatedStates[name] = stateCopy
+	}
+	for _, name := range stateNames {
+		if _, ok := def.precreatedStates[name]; !ok {
+			def.precreatedStates[name] = NewState(name)
+		}
+	}
+
+	return def, nil
+}
+
+func (def *StateMxnDefinition) GetName() string {
+	return def.smxName
+}
+
+// GetTransitionsMap returns a copy of the transitionsMap of the definition (with its patterns expanded)
+func (def *StateMxnDefinition) GetTransitionsMap() map[string][]string {
+	return copyTransitionsMap(def.transitionsMap)
+}
+
+// GetRawTransitionsMap returns a copy of the transitionsMap of the definition, as given to NewStateMxnDefinition() (with its patterns unexpanded)
+func (def *StateMxnDefinition) GetRawTransitionsMap() map[string][]string {
+	return copyTransitionsMap(def.rawTransitionsMap)
+}
+
+// GetInitialStates returns the declared initial states, or nil if not declared (and then any state can be the initial state)
+func (def *StateMxnDefinition) GetInitialStates() []string {
+	return append([]string(nil), def.initialStateNames...)
+}
+
+// GetFinalStates returns the (sorted) final states: the declared final states, or if not declared, the states without transitions
+func (def *StateMxnDefinition) GetFinalStates() []string {
+	var finalStateNames []string
+	for _, name := range allStatenames(def.transitionsMap) {
+		if def.isFinalState(name) {
+			finalStateNames = append(finalStateNames, name)
+		}
+	}
+	return finalStateNames
+}
+
+// GetFailedFinalStates returns the declared failed final states, or nil if not declared (see StatusFailed)
+func (def *StateMxnDefinition) GetFailedFinalStates() []string {
+	return append([]string(nil), def.failedFinalStateNames...)
+}
+
+// Returns true if stateName is a final state
+func (def *StateMxnDefinition) isFinalState(stateName string) bool {
+	if def.finalStateNames != nil {
+		return containsString(def.finalStateNames, stateName)
+	}
+	return isFinalStateInTransitionsMap(def.transitionsMap, stateName)
+}
+
+// Returns true if stateName can be the initial state
+func (def *StateMxnDefinition) isInitialState(stateName string) bool {
+	return def.initialStateNames == nil || containsString(def.initialStateNames, stateName)
+}
+
+func copyTransitionsMap(transitionsMap map[string][]string) map[string][]string {
+	tMap := make(map[string][]string)
+	for source, destinations := range transitionsMap {
+		tMap[source] = append([]string{}, destinations...)
+	}
+	return tMap
+}
+
+// NewInstance creates a new smachine from the definition, identified by id (see smg.GetId())
+func (def *StateMxnDefinition) NewInstance(id string) *StateMxnGeneric {
+	return newStateMxnGenericFromDefinition(def, id)
+}
+
+/*
+StateMxnManager tracks smachine instances by their id. Its safe for concurrent use
+
+	mgr := NewStateMxnManager()
+	smg, err := mgr.NewInstance(def, "order-1234")
+	...
+	smx, ok := mgr.Get("order-1234")
+*/
+type StateMxnManager struct {
+	mu        sync.RWMutex
+	instances map[string]StateMxnIfc
+}
+
+func NewStateMxnManager() *StateMxnManager {
+	return &StateMxnManager{
+		instances: make(map[string]StateMxnIfc),
+	}
+}
+
+// NewInstance creates a new instance of def with the given id, and adds it to the manager
+func (mgr *StateMxnManager) NewInstance(def *StateMxnDefinition, id string) (*StateMxnGeneric, error) {
+	smg := def.NewInstance(id)
+	if err := mgr.Add(smg); err != nil {
+		return nil, err
+	}
+	return smg, nil
+}
+
+// Add adds smx to the manager, by smx.GetId(). Returns error if there is already an instance with that id
+func (mgr *StateMxnManager) Add(smx StateMxnIfc) error {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	if _, ok := mgr.instances[smx.GetId()]; ok {
+		return fmt.Errorf("smachine instance with id '%s' already exists", smx.GetId())
+	}
+	mgr.instances[smx.GetId()] = smx
+	return nil
+}
+
+func (mgr *StateMxnManager) Get(id string) (StateMxnIfc, bool) {
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+	smx, ok := mgr.instances[id]
+	return smx, ok
+}
+
+func (mgr *StateMxnManager) Remove(id string) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	delete(mgr.instances, id)
+}
+
+// GetIds returns the (sorted) ids of all the instances in the manager
+func (mgr *StateMxnManager) GetIds() []string {
+	mgr.mu.RLock()
+	defer mgr.mu.RUnlock()
+	ids := make([]string, 0, len(mgr.instances))
+	for id := range mgr.instances {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
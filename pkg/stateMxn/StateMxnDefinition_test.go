@@ -0,0 +1,130 @@
+package stateMxn
+
+import (
+	"testing"
+)
+
+func TestNewStateMxnDefinitionValidations(t *testing.T) {
+	for name, tc := range map[string]struct {
+		transitionsMap   map[string][]string
+		precreatedStates map[string]StateIfc
+	}{
+		"empty transitionsMap":       {map[string][]string{}, nil},
+		"empty state name":           {map[string][]string{"Init": {""}}, nil},
+		"precreated with other name": {map[string][]string{"Init": {"Done"}}, map[string]StateIfc{"Init": NewState("Done")}},
+		"precreated not in map":      {map[string][]string{"Init": {"Done"}}, map[string]StateIfc{"Other": NewState("Other")}},
+	} {
+		if _, err := NewStateMxnDefinition("smx", tc.transitionsMap, tc.precreatedStates); err == nil {
+			t.Errorf("%s: NewStateMxnDefinition() did not fail", name)
+		}
+	}
+}
+
+func TestEachInstanceRunsItsOwnEnclosedSmx(t *testing.T) {
+	smxInner, err := NewStateMxnSimpleFlow("smxInner", map[string][]string{
+		"InitInner": {"FinishedOk", "FinishedNok"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smxInner.GetData()["fromTemplate"] = true
+	def, err := NewStateMxnDefinition("smxOutter", map[string][]string{
+		"Init":                   {"stateEnclosingSmxInner"},
+		"stateEnclosingSmxInner": {"Done"},
+	}, map[string]StateIfc{
+		"stateEnclosingSmxInner": NewStateEnclosingSmxSimpleflow("stateEnclosingSmxInner", smxInner, "InitInner"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var enclosedSmxs []*StateMxnSimpleflow
+	for _, smg := range []*StateMxnGeneric{def.NewInstance("first"), def.NewInstance("second")} {
+		for _, stateName := range []string{"Init", "stateEnclosingSmxInner"} {
+			if err := smg.Change(stateName); err != nil {
+				t.Fatalf("instance %s: %s", smg.GetId(), err)
+			}
+		}
+		eSmx, ok := smg.GetCurrentState().GetData()["enclosedSmx"].(*StateMxnSimpleflow)
+		if !ok {
+			t.Fatalf("instance %s: enclosedSmx is %T - want *StateMxnSimpleflow", smg.GetId(), smg.GetCurrentState().GetData()["enclosedSmx"])
+		}
+		if is, _ := eSmx.Is("FinishedOk"); !is || len(eSmx.GetHistoryOfStates()) != 2 {
+			t.Errorf("instance %s: enclosedSmx history:\n%s", smg.GetId(), eSmx.GetHistoryOfStates().DisplayStatesFlow())
+		}
+		if eSmx.GetId() != smg.GetId()+"/stateEnclosingSmxInner" || eSmx.GetData()["fromTemplate"] != true {
+			t.Errorf("instance %s: enclosedSmx id %s, data %v", smg.GetId(), eSmx.GetId(), eSmx.GetData())
+		}
+		enclosedSmxs = append(enclosedSmxs, eSmx)
+	}
+	if enclosedSmxs[0] == enclosedSmxs[1] {
+		t.Error("both instances ran the same enclosedSmx")
+	}
+	if len(smxInner.GetHistoryOfStates()) != 0 {
+		t.Errorf("the template smxInner was run:\n%s", smxInner.GetHistoryOfStates().DisplayStatesFlow())
+	}
+}
+
+func TestEnclosedSmxDefinition(t *testing.T) {
+	innerDef, err := NewStateMxnTrainflowDefinition("smxInner", []TrainMinistate{
+		{StateName: "Work", HandlerFunc: func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+			return nil
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enclosing := NewState("Enclosing")
+	enclosing.SetEnclosedSmxDefinition(innerDef)
+	enclosing.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+		return stateData["enclosedSmx"].(*StateMxnTrainflow).ChangeToInitialStateAndAutoprogressToOtherStates()
+	})
+	def, err := NewStateMxnDefinition("smxOutter", map[string][]string{
+		"Enclosing": {"Enclosing", "Done"},
+	}, map[string]StateIfc{"Enclosing": enclosing})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each activation of the state, also in the same instance, gets a new enclosedSmx
+	smg := def.NewInstance("outter")
+	for i := 0; i < 2; i++ {
+		if err := smg.Change("Enclosing"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	history := smg.GetHistoryOfStates()
+	first, second := history[0].GetData()["enclosedSmx"].(*StateMxnTrainflow), history[1].GetData()["enclosedSmx"].(*StateMxnTrainflow)
+	if first == second {
+		t.Fatal("both activations ran the same enclosedSmx")
+	}
+	for _, eSmx := range []*StateMxnTrainflow{first, second} {
+		if is, _ := eSmx.Is("FinishedOk"); !is {
+			t.Errorf("enclosedSmx history:\n%s", eSmx.GetHistoryOfStates().DisplayStatesFlow())
+		}
+	}
+}
+
+func TestEnclosedSmxOfUnknownTypeIsRejected(t *testing.T) {
+	state := NewState("Enclosing")
+	state.GetData()["enclosedSmx"] = foreignSmx{}
+	if _, err := NewStateMxnDefinition("smxOutter", map[string][]string{
+		"Enclosing": {"Done"},
+	}, map[string]StateIfc{"Enclosing": state}); err == nil {
+		t.Error("NewStateMxnDefinition() accepted an enclosedSmx that cannot be created for each activation")
+	}
+}
+
+func TestNewStateMxnFlowsReturnNilOnError(t *testing.T) {
+	if smsf, err := NewStateMxnSimpleFlow("smx", nil, nil); err == nil || smsf != nil {
+		t.Errorf("NewStateMxnSimpleFlow() = %v, %v - want nil and an error", smsf, err)
+	}
+	if smtf, err := NewStateMxnTrainFlow("smx", nil); err == nil || smtf != nil {
+		t.Errorf("NewStateMxnTrainFlow() = %v, %v - want nil and an error", smtf, err)
+	}
+}
+
+// A StateMxnIfc implemented outside of this package
+type foreignSmx struct {
+	StateMxnIfc
+}
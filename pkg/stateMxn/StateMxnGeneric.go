@@ -2,20 +2,37 @@ package stateMxn
 
 import (
 	"fmt"
-
-	"github.com/davecgh/go-spew/spew"
+	"sync"
+	"sync/atomic"
 )
 
 type StateMxnIfc interface {
 	Change(nextStateName string) error
 	Is(currentStateNameRegexp string) (bool, error)
 	GetName() (smxName string)
+	GetId() (smxId string)
 	GetTransitionsMap() (tMap map[string][]string)
 	GetCurrentState() StateIfc
 	GetHistoryOfStates() HistoryOfStates
 	GetData() StateMxnData
 	GetPlantUml() (plantUmlText string, plantUmlUrl string)
 	GetPlantUmlTransitionMap() (tm_plantUmlText string, tm_plantUmlUrl string)
+	GetClock() Clock
+}
+
+// Implemented by StateMxnGeneric (and the smachines that embed it). Its not part of StateMxnIfc, so that StateMxnIfc can be
+// implemented outside of this package. See asStateMxnGeneric()
+type stateMxnGenericGetter interface {
+	getStateMxnGeneric() *StateMxnGeneric
+}
+
+// Returns the StateMxnGeneric of smx, or ok=false when smx is a StateMxnIfc implemented outside of this package
+func asStateMxnGeneric(smx StateMxnIfc) (smg *StateMxnGeneric, ok bool) {
+	getter, ok := smx.(stateMxnGenericGetter)
+	if !ok {
+		return nil, false
+	}
+	return getter.getStateMxnGeneric(), true
 }
 
 type StateMxnData map[string]interface{}
@@ -26,12 +43,14 @@ Features that exist in any StateMxnGeneric:
   - per-state-handlers: each state can have a handlerBegin, handlerExec and handlerEnd. The execution order is: handlerBegin, handlerExec, handlerEnd.
     Both handlerBegin and handlerEnd are optional, and both will always execute even when handlerExec errors.
 
-  - state-output-input chaining: prev-state *ouput* is copied to *input* of next-state
+  - state-output-input chaining: prev-state *ouput* is copied to *input* of next-state. Which outputs are passed (all, only some keys,
+    renamed, or accumulated from all previous states) can be set per smachine or per transition - see InputMapping.go
 
   - state-data: each state has a data map[string]interface{} where you can store any internal-state-data meaningfull for that state
     See comments in code of State.data, which indicate some used keys
 
   - smachine-data: each smachine has a data map[string]interface{} where you can store any inter-state-data meaningfull for states of that smachine
+    The changes each state does into the smachine-data are recorded in state.data["smxDataDiff"] - see StateMxnDataDiff
 
   - Use `smg.Is("^Finished"")` to check if the state-machine is in a specific state (regexp)
 
@@ -39,12 +58,65 @@ Features that exist in any StateMxnGeneric:
     State.data["enclosedSmx"] is a pointer to the enclosed state-machine, and used by severall functions to detect such cases
     See example 5
 
+  - transition-actions: actions attached to a transition (source -> destination), that run between the end-handlers of the source
+    state and the begin-handlers of the destination state. See StateMxnGenericTransitionActions.go
+
+  - strict-mode: opt-in verification that handlers do not modify their inputs, and only write the smx.data keys owned by their state.
+    See StateMxnGenericStrict.go
+
+  - clock: each smachine has a Clock (see smg.SetClock()) used for all its timestamps and timers. States use the clock of their smachine,
+    and an enclosedSmx without its own clock inherits the clock of the outter smachine. Use NewFakeClock() for deterministic tests
+
+  - copy-strategy: the copy of outputs into inputs, and of the precreated-states into the activated states, is done by a Copier
+    (deep, shallow, copy-on-write, or Clone()) settable per smachine with smg.SetCopier(). See Copier.go
+
+  - errors: the errors returned wrap the sentinel errors ErrUnknownState, ErrInvalidSource, ErrInvalidTransition and ErrChangeNotAllowed,
+    and the errors of the handlers are wrapped in a HandlerError (with its smachine, state, phase, index and nesting path). See Errors.go
+
+  - handler-middlewares: smg.Use() and state.Use() wrap the handlers of the states, for logging, timing, tracing...
+    See StateHandlerMiddleware
+
+  - named-handlers: state.AddHandlerExecNamed(name, handler) (or from a HandlerRegistry) names the handler in the HandlerErrors,
+    the PlantUml diagrams and the data["handlers"] records (with the duration and result of each executed handler)
+
+  - timings: the duration of each phase and handler of each state is recorded, shown in DisplayStatesFlow() and the PlantUml
+    diagrams, and exported into a MetricsExporter (see smg.SetMetricsExporter() and StateTimings)
+
+  - panic-recovery: a panic in a handler is recovered and turned into a HandlerError (with the stack trace), and the end-handlers
+    are still executed - so a panic does not crash the process (nor the outter smachines). Disable it with smg.SetRecoverPanics(false)
+
+  - initial/final states and status: a StateMxnDefinition can declare its initial and final states (see StateMxnDefinitionOpts).
+    smg.Status() reports if the smachine is NotStarted, Running, Suspended, Completed or Failed, and smg.Change() out of a final
+    state returns a FinalStateError
+
+  - transitionsMap-patterns: sources and destinations of the transitionsMap can be "*" (as source: from any non-final state) or
+    regexps enclosed in slashes (ex: "/^Running/"). See TransitionsMapPatterns.go
+
+  - transitionsMap-analysis: use `smg.Analyze(initialStateName)` to enumerate paths, cycles, dead-end and unreachable states, and `smg.CanReach()` at runtime
+
+  - timed-transitions: a state can declare "after duration D, change to state S" with state.AddTimedTransition(). The timers use the
+    smachine clock and are cancelled when the smachine changes out of the state first. See StateMxnGenericTimers.go
+
+  - snapshots and durable-timers: a StateMxnSnapshot of the smachine can be saved into a StateMxnStore after every state-change, and
+    later restored (ex: after a process restart) re-arming any durable timed-transitions. See StateMxnSnapshot.go
+
+  - actor-mode: `smg.Start(ctx)` runs the smachine in its own goroutine, which executes the state-changes requested with `smg.Send()`
+    (while `smg.Change()` is rejected). `smg.Done()` is closed when a final state is reached
+
+  - auto-navigation: use `smg.GoTo(targetStateName, opts)` to change along the shortest path (optionally weighted and guarded) into a target state
+
+  - transition-coverage: attach a TransitionCoverage to smachines to accumulate, across runs, which states and transitions were (not) visited
+
   - PlantUml diagrams: use `smg.GetPlantUmlDiagram()` to get a PlantUml diagram of the state-machine history.
     Including any possible stateEnclosedSmx and its inner representation, as well as outputs/error of state-changes, and also resumed smachine-data and state-data of each state.
     The most usefull diagram is generated by GetPlantUmlDiagram().
     Its also posible to display transitionsMap with 'smg.GetPlantUmlDiagramOfStatesFlow()'
     An older and very primitive cli diagram can be generated with `smg.GetHistoryOfStates().DisplayStatesFlow()`
 
+Definitions and instances: a StateMxnDefinition holds the (immutable) smxName, transitionsMap and precreatedStates, and is validated once.
+Many smachines can then be created cheaply from it with def.NewInstance(id), and tracked by id in a StateMxnManager.
+NewStateMxnGeneric() creates a StateMxnDefinition and one instance of it.
+
 Overall its possible to create statemachine containint a defined transitionsMap of state.
 States can contain handlers for which they must be precreated.
 A smachine can be enclosed inside another smachine: the outter smachine will have a stateEnclosedSmx with handlers to progress the inner-smachine.
@@ -54,35 +126,130 @@ The best way to understand how to use this package is to follow the examples in
 */
 type StateMxnGeneric struct {
 	smxName        string
-	transitionsMap map[string][]string
+	transitionsMap map[string][]string // read-only, shared with definition
+
+	// definition - the StateMxnDefinition this smachine is an instance of, and id - the id of this instance
+	definition *StateMxnDefinition
+	id         string
 
-	precreatedStates map[string]StateIfc // map[<statename>]*State
+	precreatedStates map[string]StateIfc // map[<statename>]*State - read-only, shared with definition
 	currentState     StateIfc
 	historyOfStates  HistoryOfStates
 
 	// data - where different states can store inter-states data
-	// data["error"] is used to store the error of any state. Read with smg.GetError(), set with smg.setError()
+	// data["error"] is used to store the errors of any state, joined. Read with smg.GetError(), set with smg.setError()
+	// data["errors"] is the []error of all the errors, in order. Read with smg.GetErrors()
 	data StateMxnData
+
+	// clock - set with smg.SetClock(). When nil, the inheritedClock is used (or if also nil, the real clock)
+	clock Clock
+	// inheritedClock - the clock of the outter smachine, when this smachine is an enclosedSmx
+	inheritedClock Clock
+
+	// inputMapping and transitionInputMappings[<source>][<destination>] - decide the inputs of each state. See InputMapping
+	inputMapping            InputMapping
+	transitionInputMappings map[string]map[string]InputMapping
+	// initialInputs - the inputs of the initial state. See smg.SetInitialInputs()
+	initialInputs StateInputs
+
+	// middlewares - see smg.Use(). inheritedMiddlewares - the inheritable middlewares of the outter smachines, when this
+	// smachine is an enclosedSmx (see UseOpts.InheritedByEnclosedSmx)
+	middlewares          []usedMiddleware
+	inheritedMiddlewares []StateHandlerMiddleware
+
+	// metricsExporter - see smg.SetMetricsExporter(). inheritedMetricsExporter - the one of the outter smachine, when this
+	// smachine is an enclosedSmx
+	metricsExporter          MetricsExporter
+	inheritedMetricsExporter MetricsExporter
+
+	// recoverPanics - see smg.SetRecoverPanics()
+	recoverPanics bool
+	// verboseErrors - see smg.SetVerboseErrors()
+	verboseErrors bool
+	// outterNestingPath - when this smachine is an enclosedSmx, the path of the outter smachines and states (see HandlerError.NestingPath)
+	outterNestingPath []string
+
+	// suspended - see smg.Suspend(). suspendedTimedTransitions - the timed-transitions fired while suspended, deferred until smg.Resume()
+	suspended                 bool
+	suspendedTimedTransitions []dueTimedTransition
+
+	// transitionActions[<source>][<destination>] - see smg.AddTransitionAction()
+	transitionActions map[string]map[string][]transitionAction
+
+	// strictMode and dataKeyOwners[<key>] = <stateNames> - see StateMxnGenericStrict.go
+	strictMode    bool
+	dataKeyOwners map[string][]string
+	// dataKeyWriters[<key>] = <stateName> - the last state that wrote each key of data (in strict-mode, or when recordDataDiffs is enabled)
+	dataKeyWriters map[string]string
+	// recordDataDiffs - see smg.SetRecordDataDiffs()
+	recordDataDiffs bool
+
+	// copier - set with smg.SetCopier(). When nil, inputs and precreated-states are deep-copied
+	copier Copier
+
+	// coverage - when not nil, every state-change is recorded into it. See TransitionCoverage.Attach()
+	coverage *TransitionCoverage
+
+	// timers - armed for the timed-transitions of the current state, and stopped when changing out of it
+	timers []ClockTimer
+	// durableTimers - the armed durable timed-transitions, which are part of the snapshot
+	durableTimers []DurableTimer
+
+	// store - when not nil, a snapshot is saved into it (with id storeId) after every state-change. See smg.SetStore()
+	store   StateMxnStore
+	storeId string
+
+	// mu - serializes state-changes, which can come from the caller of Change() or from timed-transitions. Its held during the
+	// whole state-change: the activation of the next state (its handlers), the arming of its timers, the saving into the store and
+	// the closing of done. Lock it with smg.lockMu(), which detects a handler calling back into its own smachine
+	mu sync.Mutex
+	// muHolder - the id of the goroutine holding mu, or 0. See smg.lockMu()
+	muHolder atomic.Int64
+	// stateMu - protects currentState, historyOfStates, suspended, suspendedTimedTransitions, timers, durableTimers and actor. Its never held while the
+	// handlers run, so that the handlers can call the getters of their own smachine (GetCurrentState(), Is(), Status(), ...)
+	stateMu sync.RWMutex
+	// dueTimedTransitions - timed-transitions whose deadline already passed when armed, fired at the end of the state-change
+	// (protected by mu). See smg.armTimedTransition()
+	dueTimedTransitions []dueTimedTransition
+
+	// actor - set by smg.Start(). See StateMxnGenericActor.go
+	actor *actor
+	// restrictChange - set by the smachines that embed smg and restrict its state-changes (ex: StateMxnSimpleflow), so that the
+	// state-changes requested with smg.Send() are restricted like their Change(). Returns an error if the change is not allowed
+	restrictChange func(nextStateName string) error
+	// done - closed when a final state is reached. See smg.Done()
+	done     chan struct{}
+	doneOnce sync.Once
 }
 
 // precreatedStates can be nil
+//
+// The transitionsMap and precreatedStates are copied into a new StateMxnDefinition (see NewStateMxnDefinition()), so later
+// modifications to them do not affect the smachine.
+// To create many smachines with the same transitionsMap and precreatedStates, prefer to create a StateMxnDefinition once, and then
+// create each smachine with def.NewInstance()
 func NewStateMxnGeneric(smxName string, transitionsMap map[string][]string, precreatedStates map[string]StateIfc) (*StateMxnGeneric, error) {
+	def, err := NewStateMxnDefinition(smxName, transitionsMap, precreatedStates)
+	if err != nil {
+		return nil, err
+	}
+	return def.NewInstance(smxName), nil
+}
+
+// Used by def.NewInstance()
+func newStateMxnGenericFromDefinition(def *StateMxnDefinition, id string) *StateMxnGeneric {
 	smg := &StateMxnGeneric{}
-	// FutureImprovement: Assure transitionsMap is valid
-	// FutureImprovement: Assure precreatedStates is valid
 
-	// Define smg.smxName
-	smg.smxName = smxName
+	// Define smg.definition and smg.id
+	smg.definition = def
+	smg.id = id
 
-	// Define smg.transitionsMap
-	smg.transitionsMap = transitionsMap
+	// Define smg.smxName
+	smg.smxName = def.GetName()
 
-	// Define smg.precreatedStates from precreatedStates or from a new map
-	if precreatedStates == nil {
-		smg.precreatedStates = make(map[string]StateIfc)
-	} else {
-		smg.precreatedStates = precreatedStates
-	}
+	// Define smg.transitionsMap and smg.precreatedStates, which are shared with def and other instances (and so must not be modified)
+	smg.transitionsMap = def.transitionsMap
+	smg.precreatedStates = def.precreatedStates
 
 	// Define smg.historyOfStates
 	smg.historyOfStates = HistoryOfStates([]StateIfc{})
@@ -90,12 +257,79 @@ func NewStateMxnGeneric(smxName string, transitionsMap map[string][]string, prec
 	// Define smg.data
 	smg.data = make(StateMxnData)
 
-	return smg, nil
+	// Define smg.done
+	smg.done = make(chan struct{})
+
+	// Define smg.recoverPanics
+	smg.recoverPanics = true
+
+	// Define smg.recordDataDiffs
+	smg.recordDataDiffs = true
+
+	return smg
 }
 
 // Changes from current state to nextStateName, and executes nextStageName
 // Any error given by nextStage, will be stored (can be read with smg.GetError()) and returned by this method
+//
+// NOTE: when called from inside a handler of a state of the same smachine (or of one of its enclosedSmx), while the smachine is
+// changing state, Change() is rejected with ErrChangeNotAllowed.
+// In actor-mode (see smg.Start()) Change() is rejected with ErrChangeNotAllowed - use smg.Send() instead
 func (smg *StateMxnGeneric) Change(nextStateName string) error {
+	if smg.isInActorMode() {
+		return fmt.Errorf("%w: smachine '%s' is in actor-mode, and cannot change to '%s' - use smg.Send() instead of Change()", ErrChangeNotAllowed, smg.GetName(), nextStateName)
+	}
+	if !smg.lockMu() {
+		return smg.reentrantChangeError(nextStateName)
+	}
+	defer smg.unlockMu()
+	return smg.changeAndFollowUp(nextStateName, nil)
+}
+
+// Locks smg.mu and returns true, or returns false (without locking) if the calling goroutine already holds smg.mu - ie, its a
+// handler of the state-change in progress (or of an enclosedSmx) calling back into smg, which would otherwise deadlock
+func (smg *StateMxnGeneric) lockMu() bool {
+	gid := goroutineId()
+	if smg.muHolder.Load() == gid {
+		return false
+	}
+	smg.mu.Lock()
+	smg.muHolder.Store(gid)
+	return true
+}
+
+// Unlocks smg.mu, locked with smg.lockMu()
+func (smg *StateMxnGeneric) unlockMu() {
+	smg.muHolder.Store(0)
+	smg.mu.Unlock()
+}
+
+// Returns the error of a state-change into nextStateName requested from inside a handler of the state-change in progress
+func (smg *StateMxnGeneric) reentrantChangeError(nextStateName string) error {
+	return fmt.Errorf("%w: smachine '%s' cannot change to '%s' from inside a handler of its own state-change", ErrChangeNotAllowed, smg.GetName(), nextStateName)
+}
+
+// Executes the state-change (see smg.change()) and its follow-ups: arms the timers of the next state, saves into the store,
+// closes done if a final state was reached, and fires any timed-transitions already due. smg.mu must be locked by the caller
+func (smg *StateMxnGeneric) changeAndFollowUp(nextStateName string, nextStateData StateData) error {
+	nextState, err := smg.change(nextStateName, nextStateData)
+	if nextState != nil {
+		smg.armTimedTransitions(nextState)
+	}
+	if saveErr := smg.saveToStore(); saveErr != nil && err == nil {
+		smg.setError(saveErr)
+		err = saveErr
+	}
+	smg.closeDoneIfFinalState()
+	smg.fireDueTimedTransitions()
+	return err
+}
+
+// Executes the state-change into nextStateName (see Change()). smg.mu must be locked by the caller
+//
+// nextStateData can be nil, otherwise its entries are added to the data of nextState before its activated
+// Returns nextState if it was changed-into (even if its activation returned an error) or nil otherwise
+func (smg *StateMxnGeneric) change(nextStateName string, nextStateData StateData) (StateIfc, error) {
 	// Note: this function may be called to set initialstate in which case smg.currentState is nil
 	//
 	// Performs safety-validations:
@@ -103,6 +337,7 @@ func (smg *StateMxnGeneric) Change(nextStateName string) error {
 	//
 	// and execute the change, updating currentState, historyOfStates and possibly precreatedStates, by:
 	// - creating a nextState, from a copy-or-a-new-state in precreatedStates
+	// - running the transition-actions, from currentState to nextState (see smg.AddTransitionAction())
 	// - appending nextState to historyOfStates
 	// - setting currentState = nextState
 	// - call currentState.Activate(inputs). Any error returned will be stored with smg.setError() and returned by this function
@@ -112,6 +347,8 @@ func (smg *StateMxnGeneric) Change(nextStateName string) error {
 	// - check if its valid the transition change from currentState to nextStateName
 	{
 		// -- check if nextStateName is a valid stateName
+		// -- check if the smachine is suspended
+		// -- check if nextStateName is a declared initial state, or if currentState is a final state
 		// -- check if currentState is a valid sourcestate
 		// -- check if nextState is a valid destinationstate, from currentState
 
@@ -119,25 +356,40 @@ func (smg *StateMxnGeneric) Change(nextStateName string) error {
 		err := smg.verifyIfValidStatename(nextStateName)
 		if err != nil {
 			smg.setError(err)
-			return err
+			return nil, err
+		}
+
+		// -- check if the smachine is suspended (not stored as smx error, as its not an error of the smachine)
+		smg.stateMu.RLock()
+		suspended := smg.suspended
+		smg.stateMu.RUnlock()
+		if suspended {
+			return nil, fmt.Errorf("%w: smachine '%s' is suspended, and cannot change to '%s' - use smg.Resume() first", ErrChangeNotAllowed, smg.GetName(), nextStateName)
 		}
 
 		if smg.currentState == nil {
 			// When smg.currentState == nil this function is called to set initialstate, and then
-			// .we accept any nextStateName as valid (dont check if valid sourcestate or valid transition)
-			// .
+			// .we accept any nextStateName that is a declared initial state (or any, if not declared)
+			if !smg.definition.isInitialState(nextStateName) {
+				err = fmt.Errorf("%w: state '%s' of smachine '%s' is not a declared initial state %v", ErrInvalidTransition, nextStateName, smg.GetName(), smg.definition.initialStateNames)
+				smg.setError(err)
+				return nil, err
+			}
+		} else if smg.isFinalState(smg.currentState.GetName()) {
+			// -- check if currentState is a final state (not stored as smx error, as the smachine already finished)
+			return nil, &FinalStateError{SmxName: smg.GetName(), FinalStateName: smg.currentState.GetName(), NextStateName: nextStateName}
 		} else {
 			// -- check if currentState is a valid sourcestate
 			err = smg.verifyIfValidSourcestate(smg.currentState.GetName())
 			if err != nil {
 				smg.setError(err)
-				return err
+				return nil, err
 			}
 			// -- check if nextState is a valid destinationstate, from currentState
 			err = smg.verifyIfValidTransition(smg.currentState.GetName(), nextStateName)
 			if err != nil {
 				smg.setError(err)
-				return err
+				return nil, err
 			}
 		}
 	}
@@ -147,31 +399,72 @@ func (smg *StateMxnGeneric) Change(nextStateName string) error {
 	nextState, err := smg.getStatecopyFromPrecreatedstatesOrNew(nextStateName)
 	if err != nil {
 		smg.setError(err)
-		return err
+		return nil, err
 	}
-	oldState := smg.currentState
-	var inputs StateInputs
-	if oldState == nil {
-		// When oldState == nil this function is called to set initialstate
-		inputs = make(StateInputs)
-	} else {
-		oldState_outputs := oldState.GetOutputs()
-		inputs = oldState_outputs.Convert2Inputs()
+	for k, v := range nextStateData {
+		nextState.GetData()[k] = v
+	}
+	// - running the transition-actions (which can abort the change)
+	if smg.currentState != nil {
+		err = smg.runTransitionActions(smg.currentState, nextState)
+		if err != nil {
+			smg.setError(err)
+			return nil, err
+		}
 	}
+	smg.stopTimedTransitions()
+	oldState := smg.currentState
+	// When oldState == nil this function is called to set initialstate, and the inputs are the smg.initialInputs
+	inputs := smg.mapInputs(nextStateName)
 
+	smg.stateMu.Lock()
 	// - appending nextState to historyOfStates
 	smg.historyOfStates = append(smg.historyOfStates, nextState)
 	// - setting currentState = nextState
 	smg.currentState = nextState
+	smg.stateMu.Unlock()
 
-	// - call currentState.Activate(inputs). Any error returned will be stored with smg.setError() and returned by this function
+	if smg.coverage != nil {
+		oldStateName := ""
+		if oldState != nil {
+			oldStateName = oldState.GetName()
+		}
+		smg.coverage.record(smg, oldStateName, nextStateName)
+	}
+
+	// - call currentState.Activate(inputs). All the errors of the state will be stored with smg.setError(), and the error returned
+	//   by Activate() is returned by this function
+	smg.currentState.setSmx(smg)
+	if eSmx, ok := smg.currentState.GetData()["enclosedSmx"].(StateMxnIfc); ok {
+		if eSmg, ok := asStateMxnGeneric(eSmx); ok {
+			eSmg.inheritFromOutterSmx(smg)
+		}
+	}
+	var smDataBefore StateMxnData
+	if smg.recordDataDiffs {
+		smDataBefore = snapshotStateMxnData(smg.data)
+	}
 	_, err = smg.currentState.activate(smg.data, inputs)
+	if smg.recordDataDiffs {
+		smDataDiff := diffStateMxnData(smDataBefore, smg.data)
+		smg.currentState.GetData()["smxDataDiff"] = smDataDiff
+		smg.recordDataKeyWriters(nextStateName, smDataDiff)
+	}
+	smg.exportStateTimings(smg.currentState, err)
 	if err != nil {
-		smg.setError(err)
-		return err
+		// all the errors of the state are stored (ex: an exec-handler error followed by an end-handler error), and err is also
+		// stored in case it was not stored in the state
+		stateErrs := smg.currentState.GetErrors()
+		for _, serr := range stateErrs {
+			smg.setError(serr)
+		}
+		if !containsError(stateErrs, err) {
+			smg.setError(err)
+		}
+		return nextState, err
 	}
 
-	return nil
+	return nextState, nil
 }
 
 // Is returns true if the smg.CurrentState.Name() matches the given regexp
@@ -193,22 +486,51 @@ func (smg *StateMxnGeneric) GetName() (smxName string) {
 	return smxName
 }
 
+// GetId returns the id of this smachine instance (see def.NewInstance()). When created with NewStateMxnGeneric(), its the smxName
+func (smg *StateMxnGeneric) GetId() (smxId string) {
+	return smg.id
+}
+
+// GetDefinition returns the StateMxnDefinition this smachine is an instance of
+func (smg *StateMxnGeneric) GetDefinition() *StateMxnDefinition {
+	return smg.definition
+}
+
+// GetTransitionsMap returns the transitionsMap of the smachine, with its patterns expanded (see TransitionsMapPatterns.go).
+// It is shared with the StateMxnDefinition and all its instances, and so must not be modified
 func (smg *StateMxnGeneric) GetTransitionsMap() (tMap map[string][]string) {
 	tMap = smg.transitionsMap
 	return tMap
 }
+
+// NOTE: can be called from inside a handler of a state of the same smachine (returning the state being activated)
 func (smg *StateMxnGeneric) GetCurrentState() StateIfc {
+	smg.stateMu.RLock()
+	defer smg.stateMu.RUnlock()
 	return smg.currentState
 }
 
 // NOTE: historyOfStates[-1] == currentState
+// NOTE: can be called from inside a handler of a state of the same smachine
 func (smg *StateMxnGeneric) GetHistoryOfStates() HistoryOfStates {
+	smg.stateMu.RLock()
+	defer smg.stateMu.RUnlock()
 	return smg.historyOfStates
 }
 
+// GetData returns the smachine data map itself (not a copy).
+// In actor-mode (see smg.Start()) the map is modified by the actor goroutine, so only read it from the handlers, or after
+// the state-change future is resolved, or after smg.Done() is closed
 func (smg *StateMxnGeneric) GetData() StateMxnData {
 	return smg.data
 }
+
+// GetErrors returns all the errors of the smachine (of its states, and of its state-changes), in the order they happened
+func (smg *StateMxnGeneric) GetErrors() []error {
+	return getErrorsFromData(smg.data)
+}
+
+// GetError returns all the errors of the smachine joined (see errors.Join()), or nil
 func (smg *StateMxnGeneric) GetError() error {
 	err, ok := smg.data["error"]
 	if ok {
@@ -217,12 +539,88 @@ func (smg *StateMxnGeneric) GetError() error {
 		return nil
 	}
 }
+
+// SetClock sets the clock used by the smachine and its states. It is also inherited by any enclosedSmx without its own clock
+func (smg *StateMxnGeneric) SetClock(clock Clock) {
+	smg.clock = clock
+}
+
+// GetClock returns the clock of the smachine: the one set with smg.SetClock(), or else the one inherited from an
+// outter smachine, or else the real clock
+func (smg *StateMxnGeneric) GetClock() Clock {
+	if smg.clock != nil {
+		return smg.clock
+	}
+	if smg.inheritedClock != nil {
+		return smg.inheritedClock
+	}
+	return NewRealClock()
+}
+
+// SetCopier sets the Copier used to copy the outputs of a state into the inputs of the next state, and to copy the
+// inputs/outputs/data of the precreated-states into the states activated. Set it before the first smg.Change().
+// When nil (the default), both inputs and precreated-states are deep-copied (NewDeepCopier())
+func (smg *StateMxnGeneric) SetCopier(copier Copier) {
+	smg.copier = copier
+}
+
+// GetCopier returns the Copier set with smg.SetCopier(), or nil
+func (smg *StateMxnGeneric) GetCopier() Copier {
+	return smg.copier
+}
+
+// Returns the Copier for the precreated-states
+func (smg *StateMxnGeneric) getPrecreatedStatesCopier() Copier {
+	if smg.copier != nil {
+		return smg.copier
+	}
+	return NewDeepCopier()
+}
+
+func (smg *StateMxnGeneric) getStateMxnGeneric() *StateMxnGeneric {
+	return smg
+}
+
+// Called by the outter smachine, before activating the state that encloses this smachine (smg)
+// to pass down to smg any smachine-wide settings that smg should inherit
+func (smg *StateMxnGeneric) inheritFromOutterSmx(outter *StateMxnGeneric) {
+	smg.outterNestingPath = append(outter.getNestingPath(), outter.currentState.GetName())
+	smg.inheritedClock = outter.GetClock()
+	smg.inheritedMiddlewares = outter.getInheritableMiddlewares()
+	smg.inheritedMetricsExporter = outter.getMetricsExporter()
+	if smg.coverage == nil && outter.coverage != nil {
+		outter.coverage.Attach(smg)
+	}
+}
+
+// Analyze performs a static analysis of the transitionsMap of smg, taking initialStateName as the initial state.
+// See TransitionsMapAnalysis
+func (smg *StateMxnGeneric) Analyze(initialStateName string) *TransitionsMapAnalysis {
+	return analyzeTransitionsMap(smg.GetTransitionsMap(), initialStateName, smg.isFinalState)
+}
+
+// CanReach returns true if the transitionsMap has a path (of zero or more transitions) from sourceStateName to destinationStateName
+// When sourceStateName is "", the current state is used as the source (and if there is no current state yet, returns false)
+func (smg *StateMxnGeneric) CanReach(sourceStateName string, destinationStateName string) bool {
+	if sourceStateName == "" {
+		if smg.GetCurrentState() == nil {
+			return false
+		}
+		sourceStateName = smg.GetCurrentState().GetName()
+	}
+	return canReach(smg.GetTransitionsMap(), sourceStateName, destinationStateName)
+}
+
 func (smg *StateMxnGeneric) GetPlantUml() (plantUmlText string, plantUmlUrl string) {
 	plantUmlText, plantUmlUrl = plantUmlGen(smg, nil)
 	return plantUmlText, plantUmlUrl
 }
 func (smg *StateMxnGeneric) GetPlantUmlTransitionMap() (tm_plantUmlText string, tm_plantUmlUrl string) {
-	tm_plantUmlText, tm_plantUmlUrl = plantUmlGen4TransitionsMap(smg.GetTransitionsMap())
+	tm_plantUmlText, tm_plantUmlUrl = plantUmlGen4TransitionsMap(smg.definition.GetRawTransitionsMap(), &plantUmlGen4TransitionsMapOpts{
+		states:            smg.precreatedStates,
+		initialStateNames: smg.definition.GetInitialStates(),
+		finalStateNames:   smg.definition.finalStateNames,
+	})
 	return tm_plantUmlText, tm_plantUmlUrl
 }
 
@@ -243,7 +641,7 @@ func (smg *StateMxnGeneric) verifyIfValidStatename(stateName string) error {
 			}
 		}
 	}
-	return fmt.Errorf("state '%s' is unrecognized, invalid! The transitionsMap is:\n%s", stateName, spew.Sdump(smg.transitionsMap))
+	return fmt.Errorf("%w: '%s' of smachine '%s'%s", ErrUnknownState, stateName, smg.GetName(), smg.errorDetail())
 }
 
 func (smg *StateMxnGeneric) verifyIfValidSourcestate(stateName string) error {
@@ -251,7 +649,7 @@ func (smg *StateMxnGeneric) verifyIfValidSourcestate(stateName string) error {
 	if _, ok := smg.transitionsMap[stateName]; ok {
 		return nil
 	} else {
-		return fmt.Errorf("stateName '%s' is not a valid sourcestate! The transitionsMap is:\n%s", stateName, spew.Sdump(smg.transitionsMap))
+		return fmt.Errorf("%w: '%s' of smachine '%s' has no transitions%s", ErrInvalidSource, stateName, smg.GetName(), smg.errorDetail())
 	}
 }
 
@@ -264,7 +662,12 @@ func (smg *StateMxnGeneric) verifyIfValidTransition(source_stateName string, des
 			}
 		}
 	}
-	return fmt.Errorf("transition from sourcestate '%s' to destinationstate '%s' is not valid! The transitionsMap is:\n%s", source_stateName, destination_stateName, spew.Sdump(smg.transitionsMap))
+	return fmt.Errorf("%w: '%s' -> '%s' of smachine '%s'%s", ErrInvalidTransition, source_stateName, destination_stateName, smg.GetName(), smg.errorDetail())
+}
+
+// Returns true if stateName is a final state (the declared final states, or else the states without transitions)
+func (smg *StateMxnGeneric) isFinalState(stateName string) bool {
+	return smg.definition.isFinalState(stateName)
 }
 
 // Performs some safety-validations:
@@ -273,7 +676,9 @@ func (smg *StateMxnGeneric) verifyIfValidTransition(source_stateName string, des
 // and then return the state (correspoding to stateName), from:
 // - if precreatedStates contains that state, then return a copy of it
 // or
-// - create-and-store into precreatedStates a new state, and then return a copy of it
+// - create a new state, and return it
+//
+// NOTE: smg.precreatedStates belongs to the StateMxnDefinition (shared by all its instances), and so it is never modified here
 func (smg *StateMxnGeneric) getStatecopyFromPrecreatedstatesOrNew(stateName string) (StateIfc, error) {
 	// Performs some safety-validations:
 	// - if stateName is valid
@@ -287,21 +692,22 @@ func (smg *StateMxnGeneric) getStatecopyFromPrecreatedstatesOrNew(stateName stri
 	// and then return the state (corresponding to stateName), from:
 	// - if precreatedStates contains that state, then return a copy of it
 	// or
-	// - create-and-store into precreatedStates a new state, and then return a copy of it
+	// - create a new state, and return it
 	if stateCandidate, ok := smg.precreatedStates[stateName]; ok {
-		// precreatedStates contains that state, lets return a copy of it
-		stateCopy := stateCandidate.copy()
+		// precreatedStates contains that state, lets return a copy of it (with its own new enclosedSmx, if any)
+		stateCopy := stateCandidate.copy(smg.getPrecreatedStatesCopier())
+		if eSmxDefinition := stateCopy.GetEnclosedSmxDefinition(); eSmxDefinition != nil {
+			stateCopy.GetData()["enclosedSmx"] = eSmxDefinition.NewEnclosedSmx(smg.GetId() + "/" + stateName)
+		}
 		return stateCopy, nil
 	} else {
-		// precreatedStates does not contain that state
-		// - create-and-store into precreatedStates a new state, and then return a copy of it
-		state := NewState(stateName)
-		smg.precreatedStates[stateName] = state
-
-		stateCopy := state.copy()
-		return stateCopy, nil
+		// precreatedStates does not contain that state (should not happen, as the StateMxnDefinition precreates all states)
+		// - create a new state, and return it
+		return NewState(stateName), nil
 	}
 }
+
+// Appends err to the errors of the smachine (see smg.GetErrors()), and sets data["error"] with all of them joined
 func (smg *StateMxnGeneric) setError(err error) {
-	smg.data["error"] = err
+	setErrorInData(smg.data, err)
 }
@@ -0,0 +1,201 @@
+package stateMxn
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// size of the buffered channel of requests, of a smachine in actor-mode
+const actorMailboxSize = 64
+
+// ChangeFuture is returned by smg.Send(), and is resolved once the requested state-change is executed (or rejected)
+type ChangeFuture struct {
+	done  chan struct{}
+	state StateIfc
+	err   error
+}
+
+func newChangeFuture() *ChangeFuture {
+	return &ChangeFuture{done: make(chan struct{})}
+}
+
+func (cf *ChangeFuture) resolve(state StateIfc, err error) {
+	cf.state = state
+	cf.err = err
+	close(cf.done)
+}
+
+// Done is closed when the future is resolved
+func (cf *ChangeFuture) Done() <-chan struct{} {
+	return cf.done
+}
+
+// Wait blocks until the future is resolved, and returns the resulting current state and the error returned by smg.Change()
+func (cf *ChangeFuture) Wait() (StateIfc, error) {
+	<-cf.done
+	return cf.state, cf.err
+}
+
+// A request to the actor goroutine: either a state-change requested with smg.Send(), or a timed-transition that fired
+type actorRequest struct {
+	nextStateName string
+	future        *ChangeFuture
+
+	// timedTransition - when not nil, the timed-transition to fire (see smg.fireTimedTransition())
+	timedTransition *dueTimedTransition
+}
+
+type actor struct {
+	ctx     context.Context
+	mailbox chan actorRequest
+
+	// mu - RLock'ed while enqueuing into mailbox, and Lock'ed to set stopped
+	mu      sync.RWMutex
+	stopped bool
+}
+
+/*
+Start puts smg in actor-mode: a dedicated goroutine owns the smachine and executes, one at a time and in order, the state-changes
+requested with smg.Send(). This way the caller goroutines never touch the smachine maps directly.
+
+The goroutine runs until ctx is cancelled - after which any pending or new requests are resolved with ctx.Err().
+Use smg.Done() to wait until the smachine reaches a final state.
+
+While the goroutine runs, smg.Change() (and smg.GoTo()) are rejected with ErrChangeNotAllowed - all state-changes must go through
+smg.Send(), and are subject to the same restrictions as the Change() of the smachine that embeds smg (ex: a StateMxnSimpleflow
+rejects them). The timed-transitions that fire are also queued into the goroutine, so that its the only one changing the state.
+See smg.GetData() about reading the smachine data in actor-mode.
+*/
+func (smg *StateMxnGeneric) Start(ctx context.Context) error {
+	smg.stateMu.Lock()
+	if smg.actor != nil {
+		smg.stateMu.Unlock()
+		return fmt.Errorf("smachine '%s' was already started", smg.GetName())
+	}
+	a := &actor{
+		ctx:     ctx,
+		mailbox: make(chan actorRequest, actorMailboxSize),
+	}
+	smg.actor = a
+	smg.stateMu.Unlock()
+
+	go func() {
+		for {
+			select {
+			case req := <-a.mailbox:
+				if req.timedTransition != nil {
+					smg.fireTimedTransitionFromActor(*req.timedTransition)
+					continue
+				}
+				err := smg.changeFromActor(req.nextStateName)
+				req.future.resolve(smg.GetCurrentState(), err)
+			case <-ctx.Done():
+				a.mu.Lock()
+				a.stopped = true
+				a.mu.Unlock()
+				for {
+					select {
+					case req := <-a.mailbox:
+						if req.timedTransition != nil {
+							// the actor-mode is stopped, so the state-changes are done as without actor
+							smg.fireTimedTransitionFromActor(*req.timedTransition)
+							continue
+						}
+						req.future.resolve(smg.GetCurrentState(), ctx.Err())
+					default:
+						return
+					}
+				}
+			}
+		}
+	}()
+	return nil
+}
+
+// Like smg.Change(), but used by the actor goroutine, which owns the smachine in actor-mode. The restrictions of the smachine
+// that embeds smg (see smg.restrictChange) also apply
+func (smg *StateMxnGeneric) changeFromActor(nextStateName string) error {
+	if smg.restrictChange != nil {
+		if err := smg.restrictChange(nextStateName); err != nil {
+			return err
+		}
+	}
+	if !smg.lockMu() {
+		return smg.reentrantChangeError(nextStateName)
+	}
+	defer smg.unlockMu()
+	return smg.changeAndFollowUp(nextStateName, nil)
+}
+
+// Fires due (a timed-transition queued by smg.fireTimedTransition()) in the actor goroutine
+func (smg *StateMxnGeneric) fireTimedTransitionFromActor(due dueTimedTransition) {
+	smg.lockMu()
+	defer smg.unlockMu()
+	smg.fireTimedTransitionLocked(due.state, due.tt)
+}
+
+// Returns true if smg was started with smg.Start() and its goroutine was not yet stopped
+func (smg *StateMxnGeneric) isInActorMode() bool {
+	smg.stateMu.RLock()
+	a := smg.actor
+	smg.stateMu.RUnlock()
+	if a == nil {
+		return false
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return !a.stopped
+}
+
+// Send requests the smachine (in actor-mode, see smg.Start()) to change to nextStateName.
+// It does not wait for the state-change: use the returned future to get the resulting state and error
+func (smg *StateMxnGeneric) Send(nextStateName string) *ChangeFuture {
+	future := newChangeFuture()
+
+	smg.stateMu.RLock()
+	a := smg.actor
+	smg.stateMu.RUnlock()
+	if a == nil {
+		future.resolve(nil, fmt.Errorf("smachine '%s' is not started - use smg.Start() first", smg.GetName()))
+		return future
+	}
+	if !smg.sendToActor(actorRequest{nextStateName: nextStateName, future: future}) {
+		future.resolve(nil, a.ctx.Err())
+	}
+	return future
+}
+
+// Enqueues req into the mailbox of the actor goroutine. Returns false if smg is not in actor-mode (not started, or stopped)
+func (smg *StateMxnGeneric) sendToActor(req actorRequest) bool {
+	smg.stateMu.RLock()
+	a := smg.actor
+	smg.stateMu.RUnlock()
+	if a == nil {
+		return false
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	if a.stopped {
+		return false
+	}
+	select {
+	case a.mailbox <- req:
+		return true
+	case <-a.ctx.Done():
+		return false
+	}
+}
+
+// Done returns a channel that is closed when the smachine reaches a final state (see StateMxnDefinitionOpts.FinalStates)
+func (smg *StateMxnGeneric) Done() <-chan struct{} {
+	return smg.done
+}
+
+// Closes smg.done if the current state is a final state
+func (smg *StateMxnGeneric) closeDoneIfFinalState() {
+	currentState := smg.GetCurrentState()
+	if currentState != nil && smg.isFinalState(currentState.GetName()) {
+		smg.doneOnce.Do(func() { close(smg.done) })
+	}
+}
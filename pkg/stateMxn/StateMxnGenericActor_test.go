@@ -0,0 +1,144 @@
+package stateMxn
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newActorSmx(t *testing.T) *StateMxnGeneric {
+	t.Helper()
+	smg, err := NewStateMxnGeneric("actorSmx", map[string][]string{
+		"Init":    {"Running"},
+		"Running": {"Done"},
+		"Done":    {},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return smg
+}
+
+func TestActorModeRejectsDirectChange(t *testing.T) {
+	smg := newActorSmx(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := smg.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := smg.Change("Init"); !errors.Is(err, ErrChangeNotAllowed) {
+		t.Fatalf("Change() in actor-mode = %v - want ErrChangeNotAllowed", err)
+	}
+	if smg.GetCurrentState() != nil {
+		t.Fatalf("Change() in actor-mode changed into %s", smg.GetCurrentState().GetName())
+	}
+
+	for _, stateName := range []string{"Init", "Running", "Done"} {
+		state, err := smg.Send(stateName).Wait()
+		if err != nil {
+			t.Fatalf("Send(%s): %s", stateName, err)
+		}
+		if state.GetName() != stateName {
+			t.Fatalf("Send(%s) resolved with state %s", stateName, state.GetName())
+		}
+	}
+	select {
+	case <-smg.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Done() not closed after reaching a final state")
+	}
+}
+
+func TestActorModeStopped(t *testing.T) {
+	smg := newActorSmx(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := smg.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := smg.Send("Init").Wait(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	// once the actor goroutine stops, Send() is resolved with ctx.Err() and Change() is allowed again
+	deadline := time.Now().Add(5 * time.Second)
+	for smg.isInActorMode() {
+		if time.Now().After(deadline) {
+			t.Fatal("actor goroutine did not stop after ctx was cancelled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := smg.Send("Running").Wait(); !errors.Is(err, context.Canceled) {
+		t.Errorf("Send() after cancel = %v - want context.Canceled", err)
+	}
+	if err := smg.Change("Running"); err != nil {
+		t.Errorf("Change() after the actor stopped = %v", err)
+	}
+}
+
+func TestActorModeKeepsTheRestrictionsOfSimpleflow(t *testing.T) {
+	smsf, err := NewStateMxnSimpleFlow("actorSimpleflow", map[string][]string{
+		"Init": {"FinishedOk", "FinishedNok"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := smsf.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := smsf.Send("Init").Wait(); !errors.Is(err, ErrChangeNotAllowed) {
+		t.Fatalf("Send() to a StateMxnSimpleflow = %v - want ErrChangeNotAllowed", err)
+	}
+	if smsf.GetCurrentState() != nil {
+		t.Fatalf("Send() to a StateMxnSimpleflow changed into %s", smsf.GetCurrentState().GetName())
+	}
+}
+
+func TestActorModeFiresTimedTransitionsInTheActorGoroutine(t *testing.T) {
+	fc := NewFakeClock(fakeClockStart)
+	waiting := NewState("Waiting")
+	waiting.AddTimedTransition(10*time.Second, "TimedOut")
+	timedOut := NewState("TimedOut")
+	goroutineIds := make(chan int64, 2)
+	recordGoroutineId := func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+		goroutineIds <- goroutineId()
+		return nil
+	}
+	waiting.AddHandlerExec(recordGoroutineId)
+	timedOut.AddHandlerExec(recordGoroutineId)
+	smg, err := NewStateMxnGeneric("actorTimeoutSmx", map[string][]string{
+		"Waiting":  {"TimedOut"},
+		"TimedOut": {},
+	}, map[string]StateIfc{"Waiting": waiting, "TimedOut": timedOut})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smg.SetClock(fc)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := smg.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := smg.Send("Waiting").Wait(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the timer fires in this goroutine, but the timed-transition is done by the actor goroutine
+	fc.Advance(10 * time.Second)
+	select {
+	case <-smg.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("the timed-transition did not change into the final state")
+	}
+	actorId, timedId := <-goroutineIds, <-goroutineIds
+	if timedId != actorId {
+		t.Errorf("timed-transition done in goroutine %d - want the actor goroutine %d", timedId, actorId)
+	}
+	if timedId == goroutineId() {
+		t.Error("timed-transition done in the goroutine that advanced the clock")
+	}
+}
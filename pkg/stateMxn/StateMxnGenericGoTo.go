@@ -0,0 +1,138 @@
+package stateMxn
+
+import (
+	"fmt"
+	"math"
+)
+
+// GoToOpts are the options of smg.GoTo(). opts can be nil
+type GoToOpts struct {
+	// EdgeWeight returns the weight (cost) of the transition fromState -> toState. Must return a finite value >= 0,
+	// otherwise GoTo() returns an error
+	// When nil, all transitions have weight 1 (and the shortest path is the one with fewer transitions)
+	EdgeWeight func(fromStateName string, toStateName string) float64
+
+	// Guard returns false if the transition fromState -> toState should not be used in the path.
+	// When nil, all transitions of the transitionsMap can be used
+	Guard func(fromStateName string, toStateName string, smData StateMxnData) bool
+}
+
+// GoToResult is returned by smg.GoTo()
+type GoToResult struct {
+	// PlannedPath is the planned path of states, from the (old) current state until the target state (both included)
+	PlannedPath []string
+
+	// TakenPath is the path of states actually taken, from the (old) current state until the last state changed-into (both included)
+	// If all state-changes succeeded, then TakenPath == PlannedPath
+	TakenPath []string
+}
+
+func (gtr *GoToResult) String() string {
+	return fmt.Sprintf("planned path: %v, taken path: %v", gtr.PlannedPath, gtr.TakenPath)
+}
+
+/*
+GoTo drives the smachine from its current state into targetStateName, by computing the shortest path in the transitionsMap
+(optionally weighted per edge, and respecting guards - see GoToOpts) and then calling smg.Change() for each state in the path.
+
+It stops at the first smg.Change() that returns an error, and returns that error together with the planned and taken paths.
+The smachine must already have a current state (ie, smg.Change(initialStateName) must have been called before).
+If the current state is already targetStateName, nothing is changed.
+
+Example: abort the smachine from whatever state its in
+
+	gtr, err := smg.GoTo("FinishedNok", nil)
+*/
+func (smg *StateMxnGeneric) GoTo(targetStateName string, opts *GoToOpts) (*GoToResult, error) {
+	if opts == nil {
+		opts = &GoToOpts{}
+	}
+	gtr := &GoToResult{}
+
+	if smg.GetCurrentState() == nil {
+		return gtr, fmt.Errorf("GoTo('%s') requires a current state - use smg.Change(initialStateName) first", targetStateName)
+	}
+	if err := smg.verifyIfValidStatename(targetStateName); err != nil {
+		return gtr, err
+	}
+	currentStateName := smg.GetCurrentState().GetName()
+
+	plannedPath, found, err := smg.shortestPath(currentStateName, targetStateName, opts)
+	if err != nil {
+		return gtr, fmt.Errorf("GoTo('%s'): %w", targetStateName, err)
+	}
+	if !found {
+		return gtr, fmt.Errorf("GoTo('%s'): there is no valid path from current state '%s'", targetStateName, currentStateName)
+	}
+	gtr.PlannedPath = plannedPath
+	gtr.TakenPath = []string{currentStateName}
+
+	for _, nextStateName := range plannedPath[1:] {
+		err := smg.Change(nextStateName)
+		if smg.GetCurrentState().GetName() == nextStateName {
+			gtr.TakenPath = append(gtr.TakenPath, nextStateName)
+		}
+		if err != nil {
+			return gtr, fmt.Errorf("GoTo('%s') stopped (%s): %w", targetStateName, gtr, err)
+		}
+	}
+	return gtr, nil
+}
+
+// Dijkstra over the transitionsMap, from sourceStateName to destinationStateName.
+// Returns the path (including both source and destination) and true if found, or an error if opts.EdgeWeight returns an invalid weight
+func (smg *StateMxnGeneric) shortestPath(sourceStateName string, destinationStateName string, opts *GoToOpts) (path []string, found bool, err error) {
+	edgeWeight := opts.EdgeWeight
+	if edgeWeight == nil {
+		edgeWeight = func(fromStateName string, toStateName string) float64 { return 1 }
+	}
+	transitionsMap := smg.GetTransitionsMap()
+
+	dist := map[string]float64{sourceStateName: 0}
+	prev := make(map[string]string)
+	done := make(map[string]bool)
+	for {
+		// pick the not-done state with smallest dist (ties broken by name, to be deterministic)
+		stateName := ""
+		minDist := math.Inf(1)
+		for candidate, d := range dist {
+			if done[candidate] {
+				continue
+			}
+			if d < minDist || (d == minDist && candidate < stateName) {
+				stateName, minDist = candidate, d
+			}
+		}
+		if stateName == "" {
+			return nil, false, nil
+		}
+		if stateName == destinationStateName {
+			break
+		}
+		done[stateName] = true
+
+		for _, nextStateName := range transitionsMap[stateName] {
+			if done[nextStateName] {
+				continue
+			}
+			if opts.Guard != nil && !opts.Guard(stateName, nextStateName, smg.GetData()) {
+				continue
+			}
+			w := edgeWeight(stateName, nextStateName)
+			if math.IsNaN(w) || math.IsInf(w, 0) || w < 0 {
+				return nil, false, fmt.Errorf("invalid weight %v for transition '%s' -> '%s' - must be a finite value >= 0", w, stateName, nextStateName)
+			}
+			d := minDist + w
+			if oldDist, ok := dist[nextStateName]; !ok || d < oldDist {
+				dist[nextStateName] = d
+				prev[nextStateName] = stateName
+			}
+		}
+	}
+
+	for stateName := destinationStateName; stateName != sourceStateName; stateName = prev[stateName] {
+		path = append([]string{stateName}, path...)
+	}
+	path = append([]string{sourceStateName}, path...)
+	return path, true, nil
+}
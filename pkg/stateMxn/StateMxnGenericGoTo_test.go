@@ -0,0 +1,62 @@
+package stateMxn
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func newGoToSmx(t *testing.T) *StateMxnGeneric {
+	t.Helper()
+	smg, err := NewStateMxnGeneric("gotoSmx", map[string][]string{
+		"Init":    {"Running", "Aborted"},
+		"Running": {"Done", "Aborted"},
+		"Aborted": {},
+		"Done":    {},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := smg.Change("Init"); err != nil {
+		t.Fatal(err)
+	}
+	return smg
+}
+
+func TestGoToShortestPath(t *testing.T) {
+	smg := newGoToSmx(t)
+	gtr, err := smg.GoTo("Done", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gtr.TakenPath) != 3 || gtr.TakenPath[2] != "Done" {
+		t.Errorf("GoTo(Done): %s", gtr)
+	}
+}
+
+func TestGoToRejectsInvalidWeights(t *testing.T) {
+	for _, w := range []float64{-1, math.NaN(), math.Inf(1)} {
+		smg := newGoToSmx(t)
+		_, err := smg.GoTo("Done", &GoToOpts{
+			EdgeWeight: func(fromStateName string, toStateName string) float64 { return w },
+		})
+		if err == nil {
+			t.Errorf("GoTo() with weight %v did not fail", w)
+		}
+		if is, _ := smg.Is("Init"); !is {
+			t.Errorf("GoTo() with weight %v changed state into %s", w, smg.GetCurrentState().GetName())
+		}
+	}
+}
+
+func TestGoToNotAllowedOnSimpleflow(t *testing.T) {
+	smsf, err := NewStateMxnSimpleFlow("smsf", map[string][]string{
+		"Init": {"FinishedOk", "FinishedNok"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := smsf.GoTo("FinishedNok", nil); !errors.Is(err, ErrChangeNotAllowed) {
+		t.Errorf("GoTo() on a StateMxnSimpleflow = %v - want ErrChangeNotAllowed", err)
+	}
+}
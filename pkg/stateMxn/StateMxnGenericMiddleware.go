@@ -0,0 +1,124 @@
+package stateMxn
+
+/*
+StateHandlerMiddleware wraps the begin/exec/end-handlers of states, to add cross-cutting behaviour (logging, timing, tracing, ...)
+without wrapping each handler closure by hand:
+
+	smg.Use(func(next stateMxn.StateHandler) stateMxn.StateHandler {
+		return func(inputs stateMxn.StateInputs, outputs stateMxn.StateOutputs, stateData stateMxn.StateData, smachineData stateMxn.StateMxnData) error {
+			hInfo, _ := stateMxn.GetHandlerInfo(stateData)
+			log.Printf("%s %s-handler[%d] started", hInfo.StateName, hInfo.Phase, hInfo.HandlerIndex)
+			err := next(inputs, outputs, stateData, smachineData)
+			log.Printf("%s %s-handler[%d] finished: %v", hInfo.StateName, hInfo.Phase, hInfo.HandlerIndex, err)
+			return err
+		}
+	})
+
+Middlewares can be used in a smachine (smg.Use(), applied to all its states) and in a state (state.Use()). Each handler is wrapped by:
+
+	middlewares inherited from outter smachines  >  smachine middlewares  >  state middlewares  >  handler
+
+and in each level, the first middleware used is the outtermost. A smachine middleware used with UseOpts.InheritedByEnclosedSmx is
+also applied to the states of any enclosedSmx (and their own enclosedSmx...).
+
+The middlewares are not applied to the onError-handlers (see State.AddHandlerOnError()). A panic in a middleware is recovered like
+a panic in the handler (see smg.SetRecoverPanics()), and in strict-mode the middleware is checked like the handler (see
+StateMxnGenericStrict.go)
+*/
+type StateHandlerMiddleware func(next StateHandler) StateHandler
+
+// HandlerInfo describes the handler being executed. Its available to middlewares and handlers with GetHandlerInfo(stateData)
+type HandlerInfo struct {
+	SmxName      string
+	StateName    string
+	Phase        HandlerPhase
+	HandlerIndex int      // index of the handler in its phase, in execution order
+	HandlerName  string   // empty for handlers added without name (see State.AddHandlerExecNamed())
+	NestingPath  []string // see HandlerError.NestingPath
+}
+
+// GetHandlerInfo returns the HandlerInfo of the handler being executed, from the stateData received by the handler (or by a
+// middleware). ok is false when called outside of a handler
+func GetHandlerInfo(stateData StateData) (hInfo HandlerInfo, ok bool) {
+	hInfo, ok = stateData["handlerInfo"].(HandlerInfo)
+	return hInfo, ok
+}
+
+type UseOpts struct {
+	// InheritedByEnclosedSmx - the middlewares are also applied to the states of any enclosedSmx
+	InheritedByEnclosedSmx bool
+}
+
+type usedMiddleware struct {
+	middleware             StateHandlerMiddleware
+	inheritedByEnclosedSmx bool
+}
+
+// Use appends middlewares, applied to the begin/exec/end-handlers of all the states of smg. See StateHandlerMiddleware
+//
+// NOTE: should be called before the smachine starts changing states
+func (smg *StateMxnGeneric) Use(middleware ...StateHandlerMiddleware) {
+	smg.UseWithOpts(nil, middleware...)
+}
+
+// Like smg.Use(), with opts. opts can be nil
+func (smg *StateMxnGeneric) UseWithOpts(opts *UseOpts, middleware ...StateHandlerMiddleware) {
+	if opts == nil {
+		opts = &UseOpts{}
+	}
+	for _, mw := range middleware {
+		smg.middlewares = append(smg.middlewares, usedMiddleware{middleware: mw, inheritedByEnclosedSmx: opts.InheritedByEnclosedSmx})
+	}
+}
+
+// Returns the middlewares (of smg and its outter smachines) that an enclosedSmx of smg inherits
+func (smg *StateMxnGeneric) getInheritableMiddlewares() []StateHandlerMiddleware {
+	inheritable := append([]StateHandlerMiddleware{}, smg.inheritedMiddlewares...)
+	for _, um := range smg.middlewares {
+		if um.inheritedByEnclosedSmx {
+			inheritable = append(inheritable, um.middleware)
+		}
+	}
+	return inheritable
+}
+
+// Use appends middlewares, applied to the begin/exec/end-handlers of the state (after the smachine middlewares).
+// See StateHandlerMiddleware
+func (s *State) Use(middleware ...StateHandlerMiddleware) {
+	s.middlewares = append(s.middlewares, middleware...)
+}
+
+// Returns handler wrapped by all the middlewares that apply to the state, and setting stateData["handlerInfo"] while it runs
+func (s *State) wrapWithMiddlewares(phase HandlerPhase, index int, name string, handler StateHandler) StateHandler {
+	var middlewares []StateHandlerMiddleware
+	{
+		if s.smx != nil {
+			middlewares = append(middlewares, s.smx.inheritedMiddlewares...)
+			for _, um := range s.smx.middlewares {
+				middlewares = append(middlewares, um.middleware)
+			}
+		}
+		middlewares = append(middlewares, s.middlewares...)
+	}
+	wrapped := handler
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		wrapped = middlewares[i](wrapped)
+	}
+
+	hInfo := HandlerInfo{
+		StateName:    s.name,
+		Phase:        phase,
+		HandlerIndex: index,
+		HandlerName:  name,
+		NestingPath:  []string{s.name},
+	}
+	if s.smx != nil {
+		hInfo.SmxName = s.smx.GetName()
+		hInfo.NestingPath = append(s.smx.getNestingPath(), s.name)
+	}
+	return func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+		stateData["handlerInfo"] = hInfo
+		defer delete(stateData, "handlerInfo")
+		return wrapped(inputs, outputs, stateData, smachineData)
+	}
+}
@@ -0,0 +1,120 @@
+package stateMxn
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Returns a middleware that logs name around the handlers of the states named stateName
+func loggingMiddleware(log *[]string, name string, stateName string) StateHandlerMiddleware {
+	return func(next StateHandler) StateHandler {
+		return func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+			if hInfo, _ := GetHandlerInfo(stateData); hInfo.StateName != stateName {
+				return next(inputs, outputs, stateData, smachineData)
+			}
+			*log = append(*log, name+">")
+			err := next(inputs, outputs, stateData, smachineData)
+			*log = append(*log, "<"+name)
+			return err
+		}
+	}
+}
+
+// SmxOutter (state Enclosing) encloses SmxInner (state Running, whose exec-handler[1] is named "run")
+func newMiddlewareSmx(t *testing.T, log *[]string, hInfo *HandlerInfo) *StateMxnGeneric {
+	t.Helper()
+	running := NewState("Running")
+	running.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		return nil
+	})
+	running.AddHandlerExecNamed("run", func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		*log = append(*log, "handler")
+		*hInfo, _ = GetHandlerInfo(stateData)
+		return nil
+	})
+	running.Use(loggingMiddleware(log, "state1", "Running"), loggingMiddleware(log, "state2", "Running"))
+	smxInner, err := NewStateMxnGeneric("SmxInner", map[string][]string{
+		"Running": {"Finished"},
+	}, map[string]StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smxInner.Use(loggingMiddleware(log, "smx1", "Running"), loggingMiddleware(log, "smx2", "Running"))
+
+	enclosing := NewState("Enclosing")
+	enclosing.GetData()["enclosedSmx"] = smxInner
+	enclosing.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		return stateData["enclosedSmx"].(StateMxnIfc).Change("Running")
+	})
+	smxOutter, err := NewStateMxnGeneric("SmxOutter", map[string][]string{
+		"Enclosing": {"Finished"},
+	}, map[string]StateIfc{"Enclosing": enclosing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return smxOutter
+}
+
+func TestMiddlewaresWrapOrder(t *testing.T) {
+	var log []string
+	var hInfo HandlerInfo
+	smxOutter := newMiddlewareSmx(t, &log, &hInfo)
+	smxOutter.UseWithOpts(&UseOpts{InheritedByEnclosedSmx: true}, loggingMiddleware(&log, "outter", "Running"))
+	if err := smxOutter.Change("Enclosing"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"outter>", "smx1>", "smx2>", "state1>", "state2>",
+		"handler",
+		"<state2", "<state1", "<smx2", "<smx1", "<outter",
+	}
+	// the exec-handler[0] of Running is also wrapped, before "run"
+	if len(log) != 2*len(want)-1 || !reflect.DeepEqual(log[len(log)-len(want):], want) {
+		t.Errorf("log = %v - want it to end with %v", log, want)
+	}
+}
+
+func TestGetHandlerInfo(t *testing.T) {
+	var log []string
+	var hInfo HandlerInfo
+	smxOutter := newMiddlewareSmx(t, &log, &hInfo)
+	if err := smxOutter.Change("Enclosing"); err != nil {
+		t.Fatal(err)
+	}
+	want := HandlerInfo{
+		SmxName:      "SmxInner",
+		StateName:    "Running",
+		Phase:        HandlerPhaseExec,
+		HandlerIndex: 1,
+		HandlerName:  "run",
+		NestingPath:  []string{"SmxOutter", "Enclosing", "SmxInner", "Running"},
+	}
+	if !reflect.DeepEqual(hInfo, want) {
+		t.Errorf("GetHandlerInfo() = %+v - want %+v", hInfo, want)
+	}
+
+	eSmx := smxOutter.GetCurrentState().GetData()["enclosedSmx"].(StateMxnIfc)
+	if _, ok := GetHandlerInfo(eSmx.GetCurrentState().GetData()); ok {
+		t.Error("GetHandlerInfo() outside of a handler returned ok")
+	}
+}
+
+func TestMiddlewaresInheritedByEnclosedSmx(t *testing.T) {
+	var log []string
+	var hInfo HandlerInfo
+	smxOutter := newMiddlewareSmx(t, &log, &hInfo)
+	smxOutter.Use(loggingMiddleware(&log, "notInherited", "Running"), loggingMiddleware(&log, "outterState", "Enclosing"))
+	smxOutter.UseWithOpts(&UseOpts{InheritedByEnclosedSmx: true}, loggingMiddleware(&log, "inherited", "Running"))
+	if err := smxOutter.Change("Enclosing"); err != nil {
+		t.Fatal(err)
+	}
+	if !containsString(log, "outterState>") {
+		t.Errorf("log = %v - want the middleware of SmxOutter applied to its own states", log)
+	}
+	if containsString(log, "notInherited>") {
+		t.Errorf("log = %v - want the middleware used without InheritedByEnclosedSmx not applied to SmxInner", log)
+	}
+	if !containsString(log, "inherited>") {
+		t.Errorf("log = %v - want the middleware used with InheritedByEnclosedSmx applied to SmxInner", log)
+	}
+}
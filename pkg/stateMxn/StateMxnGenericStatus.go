@@ -0,0 +1,112 @@
+package stateMxn
+
+import "fmt"
+
+// StateMxnStatus is the lifecycle status of a smachine, returned by smg.Status()
+type StateMxnStatus int
+
+const (
+	// StatusNotStarted - there is no current state yet (smg.Change() was not yet called)
+	StatusNotStarted StateMxnStatus = iota
+	// StatusRunning - the current state is not a final state
+	StatusRunning
+	// StatusSuspended - the current state is not a final state, and the smachine was suspended with smg.Suspend() or its
+	// actor-mode was stopped (see smg.Start())
+	StatusSuspended
+	// StatusCompleted - the current state is a final state, and did not fail (see StatusFailed)
+	StatusCompleted
+	// StatusFailed - the current state is a final state, and its one of the declared failed final states (see
+	// StateMxnDefinitionOpts.FailedFinalStates), or when they are not declared, it has errors. Errors of the states before it
+	// (from which the smachine recovered, or which led into the final state) and of rejected state-changes (ex: smg.Change()
+	// into an unknown state) do not make the smachine Failed
+	StatusFailed
+)
+
+func (status StateMxnStatus) String() string {
+	switch status {
+	case StatusNotStarted:
+		return "NotStarted"
+	case StatusRunning:
+		return "Running"
+	case StatusSuspended:
+		return "Suspended"
+	case StatusCompleted:
+		return "Completed"
+	case StatusFailed:
+		return "Failed"
+	}
+	return fmt.Sprintf("StateMxnStatus(%d)", int(status))
+}
+
+// FinalStateError is returned by smg.Change() when the current state is a final state (see StateMxnDefinitionOpts.FinalStates)
+type FinalStateError struct {
+	SmxName        string
+	FinalStateName string
+	NextStateName  string
+}
+
+func (e *FinalStateError) Error() string {
+	return fmt.Sprintf("smachine '%s' is in final state '%s', and cannot change to '%s'", e.SmxName, e.FinalStateName, e.NextStateName)
+}
+
+// Unwrap makes a FinalStateError match errors.Is(err, ErrChangeNotAllowed)
+func (e *FinalStateError) Unwrap() error {
+	return ErrChangeNotAllowed
+}
+
+// Status returns the lifecycle status of the smachine. See StateMxnStatus
+//
+// NOTE: can be called from inside a handler of a state of the same smachine
+func (smg *StateMxnGeneric) Status() StateMxnStatus {
+	smg.stateMu.RLock()
+	defer smg.stateMu.RUnlock()
+	if smg.currentState == nil {
+		return StatusNotStarted
+	}
+	if smg.isFinalState(smg.currentState.GetName()) {
+		if smg.finalStateFailed() {
+			return StatusFailed
+		}
+		return StatusCompleted
+	}
+	if smg.suspended {
+		return StatusSuspended
+	}
+	if smg.actor != nil {
+		smg.actor.mu.RLock()
+		defer smg.actor.mu.RUnlock()
+		if smg.actor.stopped {
+			return StatusSuspended
+		}
+	}
+	return StatusRunning
+}
+
+// Returns true if the current (final) state is a failed final state, or if they are not declared, if it has errors.
+// smg.stateMu must be locked by the caller
+func (smg *StateMxnGeneric) finalStateFailed() bool {
+	if smg.definition.failedFinalStateNames != nil {
+		return containsString(smg.definition.failedFinalStateNames, smg.currentState.GetName())
+	}
+	return len(smg.currentState.GetErrors()) > 0
+}
+
+// Suspend makes the smachine reject any state-change until smg.Resume(). The timed-transitions that fire meanwhile are not
+// lost: they are deferred, and fired by smg.Resume() (and the durable ones stay in the snapshot, see StateMxnSnapshot)
+func (smg *StateMxnGeneric) Suspend() {
+	smg.stateMu.Lock()
+	defer smg.stateMu.Unlock()
+	smg.suspended = true
+}
+
+// Resume undoes smg.Suspend(), and fires the timed-transitions deferred while suspended
+func (smg *StateMxnGeneric) Resume() {
+	smg.stateMu.Lock()
+	smg.suspended = false
+	deferred := smg.suspendedTimedTransitions
+	smg.suspendedTimedTransitions = nil
+	smg.stateMu.Unlock()
+	for _, due := range deferred {
+		smg.fireTimedTransition(due.state, due.tt)
+	}
+}
@@ -0,0 +1,189 @@
+package stateMxn
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStatusIsBasedOnTheFinalStateReached(t *testing.T) {
+	failOnce := true
+	running := NewState("Running")
+	running.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		if failOnce {
+			failOnce = false
+			return errors.New("first run fails")
+		}
+		return nil
+	})
+	failing := NewState("FinishedBroken")
+	failing.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		return errors.New("final state fails")
+	})
+	tMap := map[string][]string{
+		"Init":    {"Running"},
+		"Running": {"Retry", "FinishedOk", "FinishedNok", "FinishedBroken"},
+		"Retry":   {"Running"},
+	}
+	precreatedStates := map[string]StateIfc{"Running": running, "FinishedBroken": failing}
+
+	// without declared failed final states: Failed only if the final state reached has errors
+	def, err := NewStateMxnDefinition("smx", tMap, precreatedStates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smg := def.NewInstance("smx")
+	_ = smg.Change("Init")
+	if err := smg.Change("Bogus"); !errors.Is(err, ErrUnknownState) {
+		t.Fatalf("Change(Bogus) = %v", err)
+	}
+	_ = smg.Change("Running") // fails
+	_ = smg.Change("Retry")
+	_ = smg.Change("Running") // recovers
+	_ = smg.Change("FinishedOk")
+	if status := smg.Status(); status != StatusCompleted {
+		t.Errorf("Status() = %s - want %s (errors: %v)", status, StatusCompleted, smg.GetErrors())
+	}
+
+	failOnce = true
+	smg = def.NewInstance("smx2")
+	_ = smg.Change("Init")
+	_ = smg.Change("Running") // fails
+	_ = smg.Change("FinishedNok")
+	if status := smg.Status(); status != StatusCompleted {
+		t.Errorf("Status() in FinishedNok without errors = %s - want %s", status, StatusCompleted)
+	}
+
+	smg = def.NewInstance("smx3")
+	_ = smg.Change("Init")
+	_ = smg.Change("Running")
+	_ = smg.Change("FinishedBroken")
+	if status := smg.Status(); status != StatusFailed {
+		t.Errorf("Status() in FinishedBroken with errors = %s - want %s", status, StatusFailed)
+	}
+
+	var fse *FinalStateError
+	if err := smg.Change("Running"); !errors.As(err, &fse) || !errors.Is(err, ErrChangeNotAllowed) {
+		t.Errorf("Change() out of a final state = %v - want a FinalStateError matching ErrChangeNotAllowed", err)
+	}
+}
+
+func TestStatusIsBasedOnTheDeclaredFailedFinalStates(t *testing.T) {
+	failing := NewState("FinishedBroken")
+	failing.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		return errors.New("final state fails")
+	})
+	tMap := map[string][]string{
+		"Init": {"FinishedOk", "FinishedNok", "FinishedBroken"},
+	}
+	def, err := NewStateMxnDefinitionWithOpts("smx", tMap, map[string]StateIfc{"FinishedBroken": failing}, &StateMxnDefinitionOpts{
+		FailedFinalStates: []string{"FinishedNok"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := def.GetFailedFinalStates(); len(got) != 1 || got[0] != "FinishedNok" {
+		t.Errorf("GetFailedFinalStates() = %v", got)
+	}
+	for finalStateName, want := range map[string]StateMxnStatus{
+		"FinishedOk":     StatusCompleted,
+		"FinishedNok":    StatusFailed,
+		"FinishedBroken": StatusCompleted,
+	} {
+		smg := def.NewInstance(finalStateName)
+		_ = smg.Change("Init")
+		_ = smg.Change(finalStateName)
+		if status := smg.Status(); status != want {
+			t.Errorf("Status() in %s = %s - want %s", finalStateName, status, want)
+		}
+	}
+
+	if _, err := NewStateMxnDefinitionWithOpts("smx", tMap, nil, &StateMxnDefinitionOpts{FailedFinalStates: []string{"Init"}}); err == nil {
+		t.Error("a failed final state with transitions was accepted")
+	}
+	if _, err := NewStateMxnDefinitionWithOpts("smx", tMap, nil, &StateMxnDefinitionOpts{FailedFinalStates: []string{"Bogus"}}); err == nil {
+		t.Error("a failed final state not in the transitionsMap was accepted")
+	}
+
+	smtf, err := NewStateMxnTrainFlow("smtf", []TrainMinistate{
+		{StateName: "Step", HandlerFunc: func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+			return errors.New("step fails")
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = smtf.ChangeToInitialStateAndAutoprogressToOtherStates()
+	if is, _ := smtf.Is("^FinishedNok$"); !is {
+		t.Fatalf("trainflow stopped at %s - want FinishedNok", smtf.GetCurrentState().GetName())
+	}
+	if status := smtf.Status(); status != StatusFailed {
+		t.Errorf("trainflow Status() in FinishedNok = %s - want %s", status, StatusFailed)
+	}
+}
+
+func TestSuspendDefersTheTimedTransitions(t *testing.T) {
+	waiting := NewState("Waiting")
+	waiting.AddDurableTimedTransition(10*time.Second, "TimedOut")
+	smg, err := NewStateMxnGeneric("smx", map[string][]string{
+		"Waiting":  {"TimedOut", "Answered"},
+		"TimedOut": {},
+		"Answered": {},
+	}, map[string]StateIfc{"Waiting": waiting})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := NewFakeClock(fakeClockStart)
+	smg.SetClock(fc)
+	if err := smg.Change("Waiting"); err != nil {
+		t.Fatal(err)
+	}
+
+	smg.Suspend()
+	fc.Advance(time.Minute)
+	if is, _ := smg.Is("^Waiting$"); !is {
+		t.Fatalf("changed to %s while suspended", smg.GetCurrentState().GetName())
+	}
+	if status := smg.Status(); status != StatusSuspended {
+		t.Errorf("Status() = %s - want %s", status, StatusSuspended)
+	}
+	if dts := smg.GetSnapshot().DurableTimers; len(dts) != 1 || dts[0].DestinationStateName != "TimedOut" {
+		t.Errorf("snapshot DurableTimers while suspended = %v - want the TimedOut timer", dts)
+	}
+
+	smg.Resume()
+	if is, _ := smg.Is("^TimedOut$"); !is {
+		t.Errorf("after Resume() in %s - want TimedOut", smg.GetCurrentState().GetName())
+	}
+}
+
+func TestSimpleflowStopsAtStatesWithLessThan2TransitionsUnlessFinalsDeclared(t *testing.T) {
+	tMap := map[string][]string{
+		"Init":    {"Running", "FinishedNok"},
+		"Running": {"Waiting"},
+		"Waiting": {"FinishedOk", "FinishedNok"},
+	}
+
+	smsf, err := NewStateMxnSimpleFlow("smsf", tMap, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := smsf.ChangeToInitialStateAndAutoprogressToOtherStates("Init"); err != nil {
+		t.Fatal(err)
+	}
+	if is, _ := smsf.Is("^Running$"); !is {
+		t.Errorf("without declared finals, stopped at %s - want Running", smsf.GetCurrentState().GetName())
+	}
+
+	def, err := NewStateMxnSimpleflowDefinitionWithOpts("smsf", tMap, nil, &StateMxnDefinitionOpts{FinalStates: []string{"FinishedOk", "FinishedNok"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smsf = def.NewInstance("smsf")
+	if err := smsf.ChangeToInitialStateAndAutoprogressToOtherStates("Init"); err != nil {
+		t.Fatal(err)
+	}
+	if is, _ := smsf.Is("^FinishedOk$"); !is {
+		t.Errorf("with declared finals, stopped at %s - want FinishedOk", smsf.GetCurrentState().GetName())
+	}
+}
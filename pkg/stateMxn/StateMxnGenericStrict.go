@@ -0,0 +1,154 @@
+package stateMxn
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+/*
+Strict-mode is an opt-in mode (see smg.SetStrictMode()) to enforce that handlers:
+  - do not modify their inputs (which may be shared with the outputs of the previous state, depending on the Copier)
+  - only write (add, change or remove) the keys of smx.data they own, as declared with smg.SetDataKeyOwners()
+
+As the handlers receive plain maps, the keys of the inputs and smx.data are recorded before each handler (copying only the maps,
+not their values) and compared after it, by identity (see StateMxnDataDiff). So strict-mode detects the keys that a handler adds,
+removes or assigns a different value to - but not in-place modifications inside a value (ex: appending to a *bytes.Buffer).
+A violation is reverted (the inputs and smx.data keys get back their previous values, the same ones and not copies) and becomes
+the error of the handler (wrapping ErrStrictModeViolation), with the state name and key.
+
+In strict-mode, the smachine also records which state last wrote each key of smx.data (see smg.GetDataKeyWriter()), as it does
+when recording the data diffs (see smg.SetRecordDataDiffs())
+*/
+
+// SetStrictMode enables or disables the strict-mode (disabled by default)
+func (smg *StateMxnGeneric) SetStrictMode(strict bool) {
+	smg.strictMode = strict
+}
+
+// SetDataKeyOwners declares that, in strict-mode, only the states stateNames can write smx.data[key]
+// Keys without owners can be written by any state
+func (smg *StateMxnGeneric) SetDataKeyOwners(key string, stateNames ...string) {
+	if smg.dataKeyOwners == nil {
+		smg.dataKeyOwners = make(map[string][]string)
+	}
+	smg.dataKeyOwners[key] = append([]string{}, stateNames...)
+}
+
+// GetDataKeyWriter returns the name of the last state that added or changed smx.data[key], or "" if none.
+// Its recorded in strict-mode, and while recording the data diffs (the default, see smg.SetRecordDataDiffs())
+func (smg *StateMxnGeneric) GetDataKeyWriter(key string) string {
+	return smg.dataKeyWriters[key]
+}
+
+// Records stateName as the writer of the keys added or changed in diff
+func (smg *StateMxnGeneric) recordDataKeyWriters(stateName string, diff StateMxnDataDiff) {
+	if smg.dataKeyWriters == nil {
+		smg.dataKeyWriters = make(map[string]string)
+	}
+	for k := range diff.Added {
+		smg.dataKeyWriters[k] = stateName
+	}
+	for k := range diff.Changed {
+		smg.dataKeyWriters[k] = stateName
+	}
+	for k := range diff.Removed {
+		delete(smg.dataKeyWriters, k)
+	}
+}
+
+// Returns true if stateName can write smx.data[key]
+func (smg *StateMxnGeneric) canWriteDataKey(stateName string, key string) bool {
+	owners, ok := smg.dataKeyOwners[key]
+	return !ok || containsString(owners, stateName)
+}
+
+// Calls handler in strict-mode: returns the handler error, or an error describing the violations (which are reverted)
+func (s *State) runHandlerStrict(handler StateHandler, smData StateMxnData) error {
+	inputsBefore := snapshotStateMxnData(StateMxnData(s.inputs))
+	smDataBefore := snapshotStateMxnData(smData)
+
+	handlerErr := handler(s.inputs, s.outputs, s.data, smData)
+
+	var violations []string
+	// inputs
+	{
+		diff := diffStateMxnData(inputsBefore, StateMxnData(s.inputs))
+		for _, k := range diff.keys() {
+			violations = append(violations, fmt.Sprintf("inputs['%s'] was modified", k))
+		}
+		if !diff.IsEmpty() {
+			diff.revert(StateMxnData(s.inputs))
+		}
+	}
+	// smx.data
+	{
+		diff := diffStateMxnData(smDataBefore, smData)
+		// the writes are split into the forbidden ones, which are reverted, and the allowed ones, whose writer is recorded
+		forbidden, allowed := newStateMxnDataDiff(), newStateMxnDataDiff()
+		for k, v := range diff.Added {
+			if s.smx.canWriteDataKey(s.name, k) {
+				allowed.Added[k] = v
+			} else {
+				forbidden.Added[k] = v
+			}
+		}
+		for k, v := range diff.Changed {
+			if s.smx.canWriteDataKey(s.name, k) {
+				allowed.Changed[k] = v
+			} else {
+				forbidden.Changed[k] = v
+			}
+		}
+		for k, v := range diff.Removed {
+			if s.smx.canWriteDataKey(s.name, k) {
+				allowed.Removed[k] = v
+			} else {
+				forbidden.Removed[k] = v
+			}
+		}
+		for _, k := range forbidden.keys() {
+			violations = append(violations, fmt.Sprintf("smx.data['%s'] is owned by %v", k, s.smx.dataKeyOwners[k]))
+		}
+		forbidden.revert(smData)
+		s.smx.recordDataKeyWriters(s.name, allowed)
+	}
+
+	if len(violations) == 0 {
+		return handlerErr
+	}
+	err := fmt.Errorf("%w in handler of state '%s': %s", ErrStrictModeViolation, s.name, strings.Join(violations, "; "))
+	if handlerErr != nil {
+		err = fmt.Errorf("%w (the handler also returned error: %w)", err, handlerErr)
+	}
+	return err
+}
+
+// Returns the sorted keys of diff
+func (diff StateMxnDataDiff) keys() []string {
+	var keys []string
+	for k := range diff.Added {
+		keys = append(keys, k)
+	}
+	for k := range diff.Changed {
+		keys = append(keys, k)
+	}
+	for k := range diff.Removed {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Undoes diff in m, putting back the old values (which are the original values, as snapshotStateMxnData() does not copy them)
+func (diff StateMxnDataDiff) revert(m map[string]interface{}) {
+	for k := range diff.Added {
+		delete(m, k)
+	}
+	for k, change := range diff.Changed {
+		m[k] = change.Old
+	}
+	for k, v := range diff.Removed {
+		m[k] = v
+	}
+}
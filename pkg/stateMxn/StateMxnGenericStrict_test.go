@@ -0,0 +1,155 @@
+package stateMxn
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStrictModeRevertsWithoutCopying(t *testing.T) {
+	inputBuf := bytes.NewBufferString("input")
+	ownedBuf := bytes.NewBufferString("owned by Init")
+
+	initState := NewState("Init")
+	initState.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		outputs["buf"] = inputBuf
+		smData["owned"] = ownedBuf
+		return nil
+	})
+	running := NewState("Running")
+	running.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		// in-place writes are not detected (nor reverted)
+		inputs["buf"].(*bytes.Buffer).WriteString(" read")
+		smData["owned"].(*bytes.Buffer).WriteString(" and Running")
+		// violations
+		inputs["buf"] = &bytes.Buffer{}
+		smData["owned"] = &bytes.Buffer{}
+		return nil
+	})
+	smg, err := NewStateMxnGeneric("strictSmx", map[string][]string{"Init": {"Running"}}, map[string]StateIfc{"Init": initState, "Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smg.SetStrictMode(true)
+	smg.SetDataKeyOwners("owned", "Init")
+
+	if err := smg.Change("Init"); err != nil {
+		t.Fatal(err)
+	}
+	err = smg.Change("Running")
+	if err == nil || !strings.Contains(err.Error(), "inputs['buf'] was modified") || !strings.Contains(err.Error(), "smx.data['owned'] is owned by [Init]") {
+		t.Fatalf("Change(Running) = %v - want strict-mode violations", err)
+	}
+
+	// reverted to the original values (not to copies of them), with their data intact
+	if got := smg.GetCurrentState().GetInputs()["buf"]; got != inputBuf {
+		t.Errorf("inputs[buf] reverted to %#v - want the original *bytes.Buffer", got)
+	}
+	if got := smg.GetData()["owned"]; got != ownedBuf {
+		t.Errorf("smx.data[owned] reverted to %#v - want the original *bytes.Buffer", got)
+	}
+	if inputBuf.String() != "input read" || ownedBuf.String() != "owned by Init and Running" {
+		t.Errorf("inputBuf = %q, ownedBuf = %q", inputBuf, ownedBuf)
+	}
+}
+
+func TestStrictModeViolationError(t *testing.T) {
+	errHandler := errors.New("handler failed")
+	running := NewState("Running")
+	running.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		smData["owned"] = "by Running"
+		return errHandler
+	})
+	smg, err := NewStateMxnGeneric("strictSmx", map[string][]string{"Init": {"Running"}}, map[string]StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smg.SetStrictMode(true)
+	smg.SetDataKeyOwners("owned", "Init")
+	if err := smg.Change("Init"); err != nil {
+		t.Fatal(err)
+	}
+
+	err = smg.Change("Running")
+	// both the violation and the error returned by the handler can be matched
+	if !errors.Is(err, ErrStrictModeViolation) || !errors.Is(err, errHandler) {
+		t.Fatalf("Change(Running) = %v - want ErrStrictModeViolation and the handler error", err)
+	}
+	var handlerErr *HandlerError
+	if !errors.As(err, &handlerErr) || handlerErr.StateName != "Running" || handlerErr.Phase != HandlerPhaseExec {
+		t.Errorf("Change(Running) = %v - want a HandlerError of the exec-handler of Running", err)
+	}
+	want := "strict-mode violation in handler of state 'Running': smx.data['owned'] is owned by [Init] (the handler also returned error: handler failed)"
+	if !strings.Contains(err.Error(), want) {
+		t.Errorf("error = %q - want it to contain %q", err.Error(), want)
+	}
+}
+
+func TestStrictModeRevertsAddedAndRemovedKeys(t *testing.T) {
+	running := NewState("Running")
+	running.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		delete(inputs, "in")
+		inputs["added"] = 1
+		delete(smData, "owned")
+		smData["ownedToo"] = 2
+		return nil
+	})
+	initState := NewState("Init")
+	initState.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		outputs["in"] = "x"
+		smData["owned"] = "by Init"
+		return nil
+	})
+	smg, err := NewStateMxnGeneric("strictSmx", map[string][]string{"Init": {"Running"}}, map[string]StateIfc{"Init": initState, "Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smg.SetStrictMode(true)
+	smg.SetDataKeyOwners("owned", "Init")
+	smg.SetDataKeyOwners("ownedToo", "Init")
+	_ = smg.Change("Init")
+
+	err = smg.Change("Running")
+	for _, want := range []string{"inputs['added'] was modified", "inputs['in'] was modified", "smx.data['owned'] is owned by [Init]", "smx.data['ownedToo'] is owned by [Init]"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("Change(Running) = %v - want it to contain %q", err, want)
+		}
+	}
+	inputs := smg.GetCurrentState().GetInputs()
+	if len(inputs) != 1 || inputs["in"] != "x" {
+		t.Errorf("inputs reverted to %v - want map[in:x]", inputs)
+	}
+	if data := smg.GetData(); data["owned"] != "by Init" || data["ownedToo"] != nil {
+		t.Errorf("smx.data reverted to %v", data)
+	}
+}
+
+func TestStrictModeRecordsDataKeyWritersWithoutDataDiffs(t *testing.T) {
+	running := NewState("Running")
+	running.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		smData["free"] = "by Running"
+		smData["owned"] = "by Running"
+		return nil
+	})
+	smg, err := NewStateMxnGeneric("strictSmx", map[string][]string{"Init": {"Running"}}, map[string]StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smg.SetRecordDataDiffs(false)
+	smg.SetStrictMode(true)
+	smg.SetDataKeyOwners("owned", "Init")
+	_ = smg.Change("Init")
+	_ = smg.Change("Running")
+
+	if _, ok := smg.GetCurrentState().GetData()["smxDataDiff"]; ok {
+		t.Error("smxDataDiff recorded after smg.SetRecordDataDiffs(false)")
+	}
+	if got := smg.GetDataKeyWriter("free"); got != "Running" {
+		t.Errorf("GetDataKeyWriter(free) = %q - want Running", got)
+	}
+	// the forbidden write was reverted, so Running is not its writer
+	if got := smg.GetDataKeyWriter("owned"); got != "" {
+		t.Errorf("GetDataKeyWriter(owned) = %q - want none", got)
+	}
+}
@@ -0,0 +1,137 @@
+package stateMxn
+
+import (
+	"time"
+)
+
+// TimedTransition is declared in a state with state.AddTimedTransition() or state.AddDurableTimedTransition()
+type TimedTransition struct {
+	After                time.Duration
+	DestinationStateName string
+
+	// Durable timed-transitions are included in the smachine snapshot, and re-armed when the smachine is restored. See StateMxnSnapshot
+	Durable bool
+}
+
+// DurableTimer is an armed durable timed-transition, with its absolute deadline. Its part of the StateMxnSnapshot
+type DurableTimer struct {
+	TimedTransition
+	SourceStateName string
+	Deadline        time.Time
+}
+
+// TimedTransitionFiring is stored in the data["firedTimedTransition"] of the state changed-into by a timed-transition,
+// so that the firings are recorded in the historyOfStates
+type TimedTransitionFiring struct {
+	TimedTransition
+	SourceStateName string
+	FiredAt         time.Time
+}
+
+func (ttf TimedTransitionFiring) String() string {
+	return "after " + ttf.After.String() + " in " + ttf.SourceStateName
+}
+
+// A timed-transition whose deadline already passed when it was armed. See smg.fireDueTimedTransitions()
+type dueTimedTransition struct {
+	state StateIfc
+	tt    TimedTransition
+}
+
+// Arms a timer (with the smachine clock) for each timed-transition of state. smg.mu must be locked by the caller
+func (smg *StateMxnGeneric) armTimedTransitions(state StateIfc) {
+	now := smg.GetClock().Now()
+	for _, tt := range state.GetTimedTransitions() {
+		smg.armTimedTransition(state, tt, now.Add(tt.After))
+	}
+}
+
+// Arms a timer (with the smachine clock) to fire tt at deadline. smg.mu must be locked by the caller
+//
+// If deadline already passed, tt is not armed in the clock (which could fire it synchronously, while smg.mu is locked) but
+// queued to be fired at the end of the current state-change. See smg.fireDueTimedTransitions()
+func (smg *StateMxnGeneric) armTimedTransition(state StateIfc, tt TimedTransition, deadline time.Time) {
+	if tt.Durable {
+		smg.stateMu.Lock()
+		smg.durableTimers = append(smg.durableTimers, DurableTimer{
+			TimedTransition: tt,
+			SourceStateName: state.GetName(),
+			Deadline:        deadline,
+		})
+		smg.stateMu.Unlock()
+	}
+	after := deadline.Sub(smg.GetClock().Now())
+	if after <= 0 {
+		smg.dueTimedTransitions = append(smg.dueTimedTransitions, dueTimedTransition{state: state, tt: tt})
+		return
+	}
+	timer := smg.GetClock().AfterFunc(after, func() {
+		smg.fireTimedTransition(state, tt)
+	})
+	smg.stateMu.Lock()
+	smg.timers = append(smg.timers, timer)
+	smg.stateMu.Unlock()
+}
+
+// Stops all the armed timers. smg.mu must be locked by the caller
+func (smg *StateMxnGeneric) stopTimedTransitions() {
+	smg.stateMu.Lock()
+	defer smg.stateMu.Unlock()
+	for _, timer := range smg.timers {
+		timer.Stop()
+	}
+	smg.timers = nil
+	smg.durableTimers = nil
+	smg.dueTimedTransitions = nil
+	smg.suspendedTimedTransitions = nil
+}
+
+// Called when the timer of tt fires: if the smachine is still in state, then changes into tt.DestinationStateName
+//
+// When the timer fires in the goroutine of the state-change in progress (ex: a handler advancing a FakeClock), tt is queued to be
+// fired at the end of that state-change (see smg.fireDueTimedTransitions()). In actor-mode, tt is queued into the actor goroutine
+func (smg *StateMxnGeneric) fireTimedTransition(state StateIfc, tt TimedTransition) {
+	due := dueTimedTransition{state: state, tt: tt}
+	if smg.muHolder.Load() == goroutineId() {
+		smg.dueTimedTransitions = append(smg.dueTimedTransitions, due)
+		return
+	}
+	if smg.sendToActor(actorRequest{timedTransition: &due}) {
+		return
+	}
+	smg.lockMu()
+	defer smg.unlockMu()
+	smg.fireTimedTransitionLocked(state, tt)
+}
+
+// Like smg.fireTimedTransition(), but smg.mu must be locked by the caller. While the smachine is suspended, tt is deferred
+// until smg.Resume()
+func (smg *StateMxnGeneric) fireTimedTransitionLocked(state StateIfc, tt TimedTransition) {
+	if smg.GetCurrentState() != state {
+		// the smachine left state before the timer fired
+		return
+	}
+	smg.stateMu.Lock()
+	if smg.suspended {
+		smg.suspendedTimedTransitions = append(smg.suspendedTimedTransitions, dueTimedTransition{state: state, tt: tt})
+		smg.stateMu.Unlock()
+		return
+	}
+	smg.stateMu.Unlock()
+	firing := TimedTransitionFiring{
+		TimedTransition: tt,
+		SourceStateName: state.GetName(),
+		FiredAt:         smg.GetClock().Now(),
+	}
+	_ = smg.changeAndFollowUp(tt.DestinationStateName, StateData{"firedTimedTransition": firing})
+}
+
+// Fires the queued timed-transitions whose deadline already passed when armed (see smg.armTimedTransition()), in order.
+// smg.mu must be locked by the caller
+func (smg *StateMxnGeneric) fireDueTimedTransitions() {
+	for len(smg.dueTimedTransitions) > 0 {
+		due := smg.dueTimedTransitions[0]
+		smg.dueTimedTransitions = smg.dueTimedTransitions[1:]
+		smg.fireTimedTransitionLocked(due.state, due.tt)
+	}
+}
@@ -0,0 +1,67 @@
+package stateMxn
+
+import "fmt"
+
+/*
+TransitionAction is work that belongs to a transition (edge of the transitionsMap), instead of to a state. Ex: "when going
+Running -> FinishedNok, send an alert".
+
+The actions of a transition run when the smachine changes sourceState -> destinationState, after the sourceState end-handlers (ie,
+when the sourceState is already finished) and before the destinationState begin-handlers. They can read the outputs and data of
+the sourceState, and read/write the data of the destinationState (which is not yet activated, so it has no inputs/outputs yet).
+
+When an action fails, its TransitionActionFailurePolicy decides:
+
+  - TransitionActionAbort: the transition is aborted (the smachine stays in sourceState), and smg.Change() returns the error
+
+  - TransitionActionRecord: the error is recorded into destinationState.data["transitionActionErrors"] ([]error) and the transition continues
+
+    smg.AddTransitionAction("Running", "FinishedNok", sendAlert, TransitionActionRecord)
+*/
+type TransitionAction func(sourceState StateIfc, destinationState StateIfc, smData StateMxnData) error
+
+type TransitionActionFailurePolicy int
+
+const (
+	TransitionActionAbort TransitionActionFailurePolicy = iota
+	TransitionActionRecord
+)
+
+type transitionAction struct {
+	action        TransitionAction
+	failurePolicy TransitionActionFailurePolicy
+}
+
+// AddTransitionAction appends an action to the transition sourceStateName -> destinationStateName, which must exist in the
+// transitionsMap. The actions of a transition run in the order they were added
+func (smg *StateMxnGeneric) AddTransitionAction(sourceStateName string, destinationStateName string, action TransitionAction, failurePolicy TransitionActionFailurePolicy) error {
+	if err := smg.verifyIfValidTransition(sourceStateName, destinationStateName); err != nil {
+		return err
+	}
+	if smg.transitionActions == nil {
+		smg.transitionActions = make(map[string]map[string][]transitionAction)
+	}
+	if smg.transitionActions[sourceStateName] == nil {
+		smg.transitionActions[sourceStateName] = make(map[string][]transitionAction)
+	}
+	smg.transitionActions[sourceStateName][destinationStateName] = append(smg.transitionActions[sourceStateName][destinationStateName], transitionAction{action, failurePolicy})
+	return nil
+}
+
+// Runs the actions of the transition sourceState -> destinationState. Returns the error of the first action that failed
+// with TransitionActionAbort (the remaining actions are not run)
+func (smg *StateMxnGeneric) runTransitionActions(sourceState StateIfc, destinationState StateIfc) error {
+	for _, ta := range smg.transitionActions[sourceState.GetName()][destinationState.GetName()] {
+		err := ta.action(sourceState, destinationState, smg.data)
+		if err == nil {
+			continue
+		}
+		err = fmt.Errorf("transition action of '%s' -> '%s' failed: %w", sourceState.GetName(), destinationState.GetName(), err)
+		if ta.failurePolicy == TransitionActionAbort {
+			return err
+		}
+		transitionActionErrors, _ := destinationState.GetData()["transitionActionErrors"].([]error)
+		destinationState.GetData()["transitionActionErrors"] = append(transitionActionErrors, err)
+	}
+	return nil
+}
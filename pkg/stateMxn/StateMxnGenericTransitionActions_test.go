@@ -0,0 +1,132 @@
+package stateMxn
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// Returns a smachine Alpha -> Beta, whose handlers append to *events
+func newTransitionActionsSmx(t *testing.T, events *[]string) *StateMxnGeneric {
+	t.Helper()
+	logEvent := func(event string) StateHandler {
+		return func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+			*events = append(*events, event)
+			return nil
+		}
+	}
+	alpha := NewState("Alpha")
+	alpha.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+		outputs["fromAlpha"] = "a"
+		return nil
+	})
+	alpha.AddHandlerEnd(logEvent("Alpha end"))
+	beta := NewState("Beta")
+	beta.AddHandlerBegin(logEvent("Beta begin"))
+	smg, err := NewStateMxnGeneric("transitionActionsSmx", map[string][]string{
+		"Alpha": {"Beta"},
+		"Beta":  {"Gamma"},
+	}, map[string]StateIfc{"Alpha": alpha, "Beta": beta})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := smg.Change("Alpha"); err != nil {
+		t.Fatal(err)
+	}
+	return smg
+}
+
+func TestTransitionActionRunsBetweenSourceEndAndDestinationBegin(t *testing.T) {
+	var events []string
+	smg := newTransitionActionsSmx(t, &events)
+	for _, name := range []string{"first action", "second action"} {
+		name := name
+		err := smg.AddTransitionAction("Alpha", "Beta", func(sourceState StateIfc, destinationState StateIfc, smData StateMxnData) error {
+			events = append(events, name)
+			destinationState.GetData()["fromAction"] = sourceState.GetOutputs()["fromAlpha"]
+			return nil
+		}, TransitionActionAbort)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := smg.Change("Beta"); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Alpha end", "first action", "second action", "Beta begin"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v - want %v", events, want)
+	}
+	if got := smg.GetCurrentState().GetData()["fromAction"]; got != "a" {
+		t.Errorf("Beta data[fromAction] = %v - want the output of Alpha", got)
+	}
+}
+
+func TestTransitionActionAbort(t *testing.T) {
+	var events []string
+	smg := newTransitionActionsSmx(t, &events)
+	errAction := errors.New("action failed")
+	_ = smg.AddTransitionAction("Alpha", "Beta", func(sourceState StateIfc, destinationState StateIfc, smData StateMxnData) error {
+		return errAction
+	}, TransitionActionAbort)
+	_ = smg.AddTransitionAction("Alpha", "Beta", func(sourceState StateIfc, destinationState StateIfc, smData StateMxnData) error {
+		events = append(events, "second action")
+		return nil
+	}, TransitionActionAbort)
+
+	err := smg.Change("Beta")
+	if !errors.Is(err, errAction) {
+		t.Fatalf("Change(Beta) = %v - want the action error", err)
+	}
+	if is, _ := smg.Is("^Alpha$"); !is || len(smg.GetHistoryOfStates()) != 1 {
+		t.Errorf("the aborted transition changed into %s", smg.GetCurrentState().GetName())
+	}
+	// neither the remaining actions nor the destination handlers run
+	if want := []string{"Alpha end"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v - want %v", events, want)
+	}
+	if errs := smg.GetErrors(); len(errs) != 1 || !errors.Is(errs[0], errAction) {
+		t.Errorf("smg.GetErrors() = %v - want the action error", errs)
+	}
+}
+
+func TestTransitionActionRecord(t *testing.T) {
+	var events []string
+	smg := newTransitionActionsSmx(t, &events)
+	errFirst, errSecond := errors.New("first failed"), errors.New("second failed")
+	for _, errAction := range []error{errFirst, errSecond} {
+		errAction := errAction
+		_ = smg.AddTransitionAction("Alpha", "Beta", func(sourceState StateIfc, destinationState StateIfc, smData StateMxnData) error {
+			return errAction
+		}, TransitionActionRecord)
+	}
+
+	if err := smg.Change("Beta"); err != nil {
+		t.Fatalf("Change(Beta) = %v - want the transition to continue", err)
+	}
+	if want := []string{"Alpha end", "Beta begin"}; !reflect.DeepEqual(events, want) {
+		t.Errorf("events = %v - want %v", events, want)
+	}
+	recorded, _ := smg.GetCurrentState().GetData()["transitionActionErrors"].([]error)
+	if len(recorded) != 2 || !errors.Is(recorded[0], errFirst) || !errors.Is(recorded[1], errSecond) {
+		t.Errorf("data[transitionActionErrors] = %v - want both action errors, in order", recorded)
+	}
+	if smg.GetError() != nil {
+		t.Errorf("smg.GetError() = %v - want no smx error", smg.GetError())
+	}
+}
+
+func TestAddTransitionActionRejectsEdgesNotInTheTransitionsMap(t *testing.T) {
+	var events []string
+	smg := newTransitionActionsSmx(t, &events)
+	noop := func(sourceState StateIfc, destinationState StateIfc, smData StateMxnData) error { return nil }
+	if err := smg.AddTransitionAction("Alpha", "Gamma", noop, TransitionActionAbort); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("AddTransitionAction(Alpha, Gamma) = %v - want ErrInvalidTransition", err)
+	}
+	if err := smg.AddTransitionAction("Alpha", "Unknown", noop, TransitionActionAbort); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("AddTransitionAction(Alpha, Unknown) = %v - want ErrInvalidTransition", err)
+	}
+	if err := smg.AddTransitionAction("Gamma", "Beta", noop, TransitionActionAbort); err == nil {
+		t.Error("AddTransitionAction(Gamma, Beta) did not fail")
+	}
+}
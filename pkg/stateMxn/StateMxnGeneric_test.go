@@ -0,0 +1,193 @@
+package stateMxn
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Runs f, failing the test if it does not return within timeout (ex: because of a deadlock)
+func runWithTimeout(t *testing.T, timeout time.Duration, f func()) {
+	t.Helper()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		t.Fatalf("did not return within %s (deadlock?)", timeout)
+	}
+}
+
+func TestHandlerCanReadItsOwnSmachine(t *testing.T) {
+	var smg *StateMxnGeneric
+	var isRunning bool
+	var isErr error
+	var currentStateName string
+	var historyLen int
+	var status StateMxnStatus
+
+	running := NewState("Running")
+	running.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+		isRunning, isErr = smg.Is("Running")
+		currentStateName = smg.GetCurrentState().GetName()
+		historyLen = len(smg.GetHistoryOfStates())
+		status = smg.Status()
+		return nil
+	})
+	var err error
+	smg, err = NewStateMxnGeneric("smx", map[string][]string{
+		"Init":    {"Running"},
+		"Running": {"Done"},
+	}, map[string]StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	runWithTimeout(t, 5*time.Second, func() {
+		if err := smg.Change("Init"); err != nil {
+			t.Error(err)
+		}
+		if err := smg.Change("Running"); err != nil {
+			t.Error(err)
+		}
+	})
+	if isErr != nil || !isRunning {
+		t.Errorf("smg.Is(\"Running\") from handler = %v, %v - want true, nil", isRunning, isErr)
+	}
+	if currentStateName != "Running" {
+		t.Errorf("current state from handler = %q - want \"Running\"", currentStateName)
+	}
+	if historyLen != 2 {
+		t.Errorf("history length from handler = %d - want 2", historyLen)
+	}
+	if status != StatusRunning {
+		t.Errorf("status from handler = %s - want %s", status, StatusRunning)
+	}
+}
+
+func TestHandlerCallingBackIntoItsOwnSmachine(t *testing.T) {
+	fc := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	var smg *StateMxnGeneric
+	var changeErr, restoreErr error
+	var snapshot *StateMxnSnapshot
+
+	running := NewState("Running")
+	running.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+		changeErr = smg.Change("Done")
+		snapshot = smg.GetSnapshot()
+		restoreErr = smg.RestoreFromSnapshot(snapshot)
+		// advancing the clock from a handler does not deadlock
+		fc.Advance(time.Minute)
+		return nil
+	})
+	initState := NewState("Init")
+	initState.AddTimedTransition(time.Second, "Done")
+	var err error
+	smg, err = NewStateMxnGeneric("smx", map[string][]string{
+		"Init":    {"Running", "Done"},
+		"Running": {"Done"},
+	}, map[string]StateIfc{"Init": initState, "Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smg.SetClock(fc)
+
+	runWithTimeout(t, 5*time.Second, func() {
+		_ = smg.Change("Init")
+		if err := smg.Change("Running"); err != nil {
+			t.Error(err)
+		}
+	})
+	if !errors.Is(changeErr, ErrChangeNotAllowed) {
+		t.Errorf("Change() from a handler = %v - want ErrChangeNotAllowed", changeErr)
+	}
+	if !errors.Is(restoreErr, ErrChangeNotAllowed) {
+		t.Errorf("RestoreFromSnapshot() from a handler = %v - want ErrChangeNotAllowed", restoreErr)
+	}
+	if snapshot == nil || len(snapshot.HistoryOfStates) != 2 || snapshot.HistoryOfStates[1].Name != "Running" {
+		t.Errorf("GetSnapshot() from a handler = %+v", snapshot)
+	}
+	// the timed-transition of Init was cancelled when leaving Init, so the smachine stays in Running
+	if is, _ := smg.Is("^Running$"); !is {
+		t.Errorf("current state = %s - want Running\n%s", smg.GetCurrentState().GetName(), smg.GetHistoryOfStates().DisplayStatesFlow())
+	}
+}
+
+// Concurrent Change() calls and timed-transitions (with the real clock): run with -race
+func TestConcurrentChangesAndTimedTransitions(t *testing.T) {
+	ping := NewState("Ping")
+	ping.AddTimedTransition(time.Millisecond, "Pong")
+	pong := NewState("Pong")
+	pong.AddTimedTransition(time.Millisecond, "Ping")
+	smg, err := NewStateMxnGeneric("pingpong", map[string][]string{
+		"Ping": {"Pong"},
+		"Pong": {"Ping"},
+	}, map[string]StateIfc{"Ping": ping, "Pong": pong})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := smg.Change("Ping"); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				// some of these are invalid transitions (ex: Ping->Ping), which are rejected
+				if (g+i)%2 == 0 {
+					_ = smg.Change("Ping")
+				} else {
+					_ = smg.Change("Pong")
+				}
+				_, _ = smg.Is("Ping")
+				_ = smg.GetHistoryOfStates()
+				_ = smg.Status()
+			}
+		}(g)
+	}
+	runWithTimeout(t, 10*time.Second, wg.Wait)
+	time.Sleep(5 * time.Millisecond)
+	smg.Suspend()
+
+	// each state must follow the previous one in the transitionsMap, and a timed-transition must have been armed in the previous state
+	history := smg.GetHistoryOfStates()
+	for i := 1; i < len(history); i++ {
+		prev, cur := history[i-1].GetName(), history[i].GetName()
+		if prev == cur {
+			t.Fatalf("history[%d]: invalid transition %s -> %s", i, prev, cur)
+		}
+		if ttf, ok := history[i].GetData()["firedTimedTransition"].(TimedTransitionFiring); ok && ttf.SourceStateName != prev {
+			t.Fatalf("history[%d]: timed-transition fired from %s, but the previous state was %s", i, ttf.SourceStateName, prev)
+		}
+	}
+}
+
+func TestDueTimedTransitionFiresAfterTheChange(t *testing.T) {
+	fc := NewFakeClock(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	waiting := NewState("Waiting")
+	waiting.AddTimedTransition(0, "TimedOut")
+	smg, err := NewStateMxnGeneric("smx", map[string][]string{
+		"Waiting":  {"TimedOut"},
+		"TimedOut": {},
+	}, map[string]StateIfc{"Waiting": waiting})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smg.SetClock(fc)
+
+	runWithTimeout(t, 5*time.Second, func() {
+		if err := smg.Change("Waiting"); err != nil {
+			t.Error(err)
+		}
+	})
+	if is, _ := smg.Is("TimedOut"); !is {
+		t.Errorf("current state = %s - want TimedOut", smg.GetCurrentState().GetName())
+	}
+}
@@ -16,6 +16,9 @@ The state machine will take each state, execute its handlers with state.Activate
   - if there is no error, it will change to "Ok" state which assumed to be sm.transitionsMap[state.GetName()][0], and progress from there
   - if there is an error, it will change to "Nok" state which is assumed to be sm.transitionsMap[state.GetName()][-1]
 
+The final states, where the progression stops, are the states declared as final (see NewStateMxnSimpleflowDefinitionWithOpts()).
+When no final states are declared, the progression stops at any state with less than 2 transitions (as it has no Ok and Nok states).
+
 So overall, this statemachine should take some precreated states, each with 2 transitions and 0-or-more-handlersExec, and will automatically
 progress the execution from state to state, until it reaches a finalstate, or an error occurs.
 
@@ -30,25 +33,54 @@ type StateMxnSimpleflow struct {
 	*StateMxnGeneric
 }
 
-// Will create a new StateMxnSimpleflow
+// Will create a new StateMxnSimpleflow (see NewStateMxnGeneric())
 func NewStateMxnSimpleFlow(smxName string, transitionsMap map[string][]string, precreatedStates map[string]StateIfc) (*StateMxnSimpleflow, error) {
-	// call constructor for StateMxnGeneric
-	smg, err := NewStateMxnGeneric(smxName, transitionsMap, precreatedStates)
-	smsf := &StateMxnSimpleflow{
-		StateMxnGeneric: smg,
+	// call constructor for StateMxnSimpleflowDefinition
+	def, err := NewStateMxnSimpleflowDefinition(smxName, transitionsMap, precreatedStates)
+	if err != nil {
+		return nil, err
 	}
+	return def.NewInstance(smxName), nil
+}
+
+// StateMxnSimpleflowDefinition is the StateMxnDefinition of StateMxnSimpleflow instances
+type StateMxnSimpleflowDefinition struct {
+	*StateMxnDefinition
+}
+
+func NewStateMxnSimpleflowDefinition(smxName string, transitionsMap map[string][]string, precreatedStates map[string]StateIfc) (*StateMxnSimpleflowDefinition, error) {
+	return NewStateMxnSimpleflowDefinitionWithOpts(smxName, transitionsMap, precreatedStates, nil)
+}
+
+// Like NewStateMxnSimpleflowDefinition() but declaring the initial and final states (see StateMxnDefinitionOpts). opts can be nil
+func NewStateMxnSimpleflowDefinitionWithOpts(smxName string, transitionsMap map[string][]string, precreatedStates map[string]StateIfc, opts *StateMxnDefinitionOpts) (*StateMxnSimpleflowDefinition, error) {
+	def, err := NewStateMxnDefinitionWithOpts(smxName, transitionsMap, precreatedStates, opts)
 	if err != nil {
-		return smsf, err
+		return nil, err
 	}
+	return &StateMxnSimpleflowDefinition{StateMxnDefinition: def}, nil
+}
 
-	return smsf, err
+// NewInstance creates a new StateMxnSimpleflow from the definition, identified by id
+func (def *StateMxnSimpleflowDefinition) NewInstance(id string) *StateMxnSimpleflow {
+	smsf := &StateMxnSimpleflow{
+		StateMxnGeneric: def.StateMxnDefinition.NewInstance(id),
+	}
+	smsf.StateMxnGeneric.restrictChange = smsf.restrictChange
+	return smsf
 }
 
 // This function will automatically progress through the states, until it reaches a final state or an error occurs
 func (smsf *StateMxnSimpleflow) ChangeToInitialStateAndAutoprogressToOtherStates(initialstateName string) error {
+	// When final states are declared (see StateMxnDefinitionOpts.FinalStates) they have no Ok/Nok transitions, and a state with a
+	// single transition has it as both Ok and Nok. Otherwise, a state with less than 2 transitions has no Ok/Nok transitions
 	hasOkNokTransitionsFunc := func(stateName string) (hasOkNokTransitions bool, OkStatename string, NokStatename string) {
 		tMap := smsf.GetTransitionsMap()
-		if len(tMap[stateName]) < 2 {
+		if smsf.definition.finalStateNames != nil {
+			if smsf.isFinalState(stateName) || len(tMap[stateName]) == 0 {
+				return false, "", ""
+			}
+		} else if len(tMap[stateName]) < 2 {
 			return false, "", ""
 		}
 		OkStatename = tMap[stateName][0]
@@ -71,8 +103,10 @@ func (smsf *StateMxnSimpleflow) ChangeToInitialStateAndAutoprogressToOtherStates
 		} else {
 			// serr is not nil, Change returned error
 
-			// Save error into smx-data
-			smsf.setError(serr)
+			// Save error into smx-data (unless it was already stored by Change(), as the last error of the smachine)
+			if errs := smsf.GetErrors(); len(errs) == 0 || !sameValue(errs[len(errs)-1], serr) {
+				smsf.setError(serr)
+			}
 			if !hasOkNokTransitions {
 				// this state has no transitions defined, so this is a final-state
 				// return smfs.GetError(), which in this case will be serr just stored before
@@ -84,5 +118,15 @@ func (smsf *StateMxnSimpleflow) ChangeToInitialStateAndAutoprogressToOtherStates
 }
 
 func (smf *StateMxnSimpleflow) Change(stateName string) error {
-	return fmt.Errorf("Change() method is not allowed for StateMxnSimpleflow. Use ChangeToInitialStateAndAutoprogressToOtherStates() instead")
+	return smf.restrictChange(stateName)
+}
+
+// Returns the error of smf.Change(), which is also returned for the state-changes requested with smf.Send() in actor-mode
+func (smf *StateMxnSimpleflow) restrictChange(stateName string) error {
+	return fmt.Errorf("%w: Change() method is not allowed for StateMxnSimpleflow. Use ChangeToInitialStateAndAutoprogressToOtherStates() instead", ErrChangeNotAllowed)
+}
+
+// GoTo is not allowed, as it would change states like Change() does
+func (smf *StateMxnSimpleflow) GoTo(targetStateName string, opts *GoToOpts) (*GoToResult, error) {
+	return &GoToResult{}, fmt.Errorf("%w: GoTo() method is not allowed for StateMxnSimpleflow. Use ChangeToInitialStateAndAutoprogressToOtherStates() instead", ErrChangeNotAllowed)
 }
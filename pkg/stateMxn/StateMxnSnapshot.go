@@ -0,0 +1,192 @@
+package stateMxn
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+/*
+StateMxnSnapshot is the persistable (JSON serializable) representation of a smachine: its historyOfStates, smx.data and
+armed durable timers. See smg.GetSnapshot() and smg.RestoreFromSnapshot()
+
+Only the outputs, errors and timestamps of each state are kept (not the inputs nor the state data), and all values of outputs and
+smx.data should be JSON serializable - when restored from a StateMxnStore they will be the JSON-decoded values
+(ex: an int becomes a float64). Errors are kept as their error messages (all of them, in order).
+
+Typical usage with a StateMxnStore, for long-running workflows which wait for days on durable timed-transitions:
+
+	smg, _ := NewStateMxnGeneric("Approval", transitionsMap, precreatedStates) // waitingState.AddDurableTimedTransition(72*time.Hour, "Expired")
+	smg.SetStore(store, "approval-1234")
+	err := smg.RestoreFromStore()
+	if errors.Is(err, ErrSnapshotNotFound) {
+		err = smg.Change("Init")   // first run
+	}
+	// ... after a process restart, the same code restores the smachine and re-arms the durable timers
+*/
+type StateMxnSnapshot struct {
+	SmxName         string
+	HistoryOfStates []StateSnapshot
+	Data            map[string]interface{}
+	Errors          []string
+	DurableTimers   []DurableTimer
+	SavedAt         time.Time
+}
+
+type StateSnapshot struct {
+	Name      string
+	Outputs   map[string]interface{}
+	Errors    []string
+	TimeStart time.Time
+	TimeEnd   time.Time
+}
+
+// GetSnapshot returns a snapshot of the current smachine, taken between state-changes
+//
+// NOTE: when called from inside a handler of a state of the same smachine, the snapshot is taken in the middle of the
+// state-change (ex: the state being activated is already the current state, but without its outputs yet)
+func (smg *StateMxnGeneric) GetSnapshot() *StateMxnSnapshot {
+	if !smg.lockMu() {
+		// called from a handler of the state-change in progress, which already holds smg.mu
+		return smg.getSnapshot()
+	}
+	defer smg.unlockMu()
+	return smg.getSnapshot()
+}
+
+// Like smg.GetSnapshot(), but smg.mu must be locked by the caller
+func (smg *StateMxnGeneric) getSnapshot() *StateMxnSnapshot {
+	smg.stateMu.RLock()
+	defer smg.stateMu.RUnlock()
+
+	snapshot := &StateMxnSnapshot{
+		SmxName:       smg.GetName(),
+		Data:          make(map[string]interface{}),
+		DurableTimers: append([]DurableTimer{}, smg.durableTimers...),
+		SavedAt:       smg.GetClock().Now(),
+	}
+	for _, state := range smg.historyOfStates {
+		stateSnapshot := StateSnapshot{
+			Name:    state.GetName(),
+			Outputs: make(map[string]interface{}),
+		}
+		for k, v := range state.GetOutputs() {
+			stateSnapshot.Outputs[k] = v
+		}
+		for _, err := range state.GetErrors() {
+			stateSnapshot.Errors = append(stateSnapshot.Errors, err.Error())
+		}
+		stateSnapshot.TimeStart, _ = state.GetData()["timeStart"].(time.Time)
+		stateSnapshot.TimeEnd, _ = state.GetData()["timeEnd"].(time.Time)
+		snapshot.HistoryOfStates = append(snapshot.HistoryOfStates, stateSnapshot)
+	}
+	for k, v := range smg.data {
+		if k == "error" {
+			continue
+		}
+		if k == "errors" {
+			for _, err := range smg.GetErrors() {
+				snapshot.Errors = append(snapshot.Errors, err.Error())
+			}
+			continue
+		}
+		snapshot.Data[k] = v
+	}
+	return snapshot
+}
+
+// RestoreFromSnapshot restores snapshot into smg, which must have been created with the same transitionsMap and precreatedStates
+// as the smachine of the snapshot, and must not have any current state yet.
+//
+// The states are restored without being activated (ie, their handlers are not executed).
+// The durable timers of the current state are re-armed, and any whose deadline already passed is fired immediately.
+// Non-durable timed-transitions are not restored.
+func (smg *StateMxnGeneric) RestoreFromSnapshot(snapshot *StateMxnSnapshot) error {
+	if !smg.lockMu() {
+		return fmt.Errorf("%w: cannot restore snapshot into smachine '%s' from inside a handler of its own state-change", ErrChangeNotAllowed, smg.GetName())
+	}
+	defer smg.unlockMu()
+	if currentState := smg.GetCurrentState(); currentState != nil {
+		return fmt.Errorf("cannot restore snapshot into smachine '%s', which already has current state '%s'", smg.GetName(), currentState.GetName())
+	}
+	if snapshot.SmxName != smg.GetName() {
+		return fmt.Errorf("cannot restore snapshot of smachine '%s' into smachine '%s'", snapshot.SmxName, smg.GetName())
+	}
+
+	var historyOfStates HistoryOfStates
+	for _, stateSnapshot := range snapshot.HistoryOfStates {
+		state, err := smg.getStatecopyFromPrecreatedstatesOrNew(stateSnapshot.Name)
+		if err != nil {
+			return err
+		}
+		state.setSmx(smg)
+		for k, v := range stateSnapshot.Outputs {
+			state.GetOutputs()[k] = v
+		}
+		for _, errStr := range stateSnapshot.Errors {
+			setErrorInData(state.GetData(), errors.New(errStr))
+		}
+		if !stateSnapshot.TimeStart.IsZero() {
+			state.GetData()["timeStart"] = stateSnapshot.TimeStart
+		}
+		if !stateSnapshot.TimeEnd.IsZero() {
+			state.GetData()["timeEnd"] = stateSnapshot.TimeEnd
+			state.GetData()["timeElapsed"] = stateSnapshot.TimeEnd.Sub(stateSnapshot.TimeStart)
+		}
+		historyOfStates = append(historyOfStates, state)
+	}
+	smg.stateMu.Lock()
+	smg.historyOfStates = historyOfStates
+	if len(historyOfStates) > 0 {
+		smg.currentState = historyOfStates[len(historyOfStates)-1]
+	}
+	currentState := smg.currentState
+	smg.stateMu.Unlock()
+	for k, v := range snapshot.Data {
+		smg.data[k] = v
+	}
+	for _, errStr := range snapshot.Errors {
+		smg.setError(errors.New(errStr))
+	}
+
+	// re-arm the durable timers
+	for _, durableTimer := range snapshot.DurableTimers {
+		if currentState == nil || durableTimer.SourceStateName != currentState.GetName() {
+			continue
+		}
+		smg.armTimedTransition(currentState, durableTimer.TimedTransition, durableTimer.Deadline)
+	}
+	smg.closeDoneIfFinalState()
+	smg.fireDueTimedTransitions()
+	return nil
+}
+
+// SetStore makes smg save a snapshot into store (with the given id) after every state-change. See smg.RestoreFromStore()
+func (smg *StateMxnGeneric) SetStore(store StateMxnStore, id string) {
+	smg.store = store
+	smg.storeId = id
+}
+
+// RestoreFromStore loads the snapshot from the store set with smg.SetStore() and restores it (see smg.RestoreFromSnapshot()).
+// If the store has no snapshot for the id, returns an error matching errors.Is(err, ErrSnapshotNotFound)
+func (smg *StateMxnGeneric) RestoreFromStore() error {
+	if smg.store == nil {
+		return fmt.Errorf("smachine '%s' has no store - use smg.SetStore() first", smg.GetName())
+	}
+	snapshot, err := smg.store.Load(smg.storeId)
+	if err != nil {
+		return err
+	}
+	return smg.RestoreFromSnapshot(snapshot)
+}
+
+// Saves a snapshot into smg.store, if set
+func (smg *StateMxnGeneric) saveToStore() error {
+	if smg.store == nil {
+		return nil
+	}
+	if err := smg.store.Save(smg.storeId, smg.getSnapshot()); err != nil {
+		return fmt.Errorf("smachine '%s' could not save snapshot '%s': %w", smg.GetName(), smg.storeId, err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package stateMxn
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// StateMxnStore persists StateMxnSnapshot's by id. See smg.SetStore()
+type StateMxnStore interface {
+	Save(id string, snapshot *StateMxnSnapshot) error
+	// Load returns an error matching errors.Is(err, ErrSnapshotNotFound) when there is no snapshot for id
+	Load(id string) (*StateMxnSnapshot, error)
+}
+
+var ErrSnapshotNotFound = errors.New("snapshot not found")
+
+// MemoryStore is an in-memory StateMxnStore. The snapshots are kept JSON-encoded, so that loading them behaves as with a FileStore
+// (useful to simulate process restarts in tests)
+type MemoryStore struct {
+	mu        sync.Mutex
+	snapshots map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		snapshots: make(map[string][]byte),
+	}
+}
+
+func (ms *MemoryStore) Save(id string, snapshot *StateMxnSnapshot) error {
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.snapshots[id] = b
+	return nil
+}
+
+func (ms *MemoryStore) Load(id string) (*StateMxnSnapshot, error) {
+	ms.mu.Lock()
+	b, ok := ms.snapshots[id]
+	ms.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("id '%s': %w", id, ErrSnapshotNotFound)
+	}
+	snapshot := &StateMxnSnapshot{}
+	if err := json.Unmarshal(b, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// FileStore is a StateMxnStore that keeps each snapshot as a JSON file <dir>/<hex(id)>.json
+//
+// The id is hex-encoded (see FileStore.IdOfFilename() to decode it) so that different ids never share the same file - not even in
+// case-insensitive filesystems. Ids too long to fit in a filename are rejected by Save() and Load()
+type FileStore struct {
+	dir string
+}
+
+// NewFileStore creates dir if it does not exist
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// Save writes the snapshot into a temporary file and then renames it, so that an interrupted Save never leaves a corrupted snapshot
+func (fs *FileStore) Save(id string, snapshot *StateMxnSnapshot) error {
+	b, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	file, err := fs.filepath(id)
+	if err != nil {
+		return err
+	}
+	tmpFile := file + ".tmp"
+	if err := os.WriteFile(tmpFile, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, file)
+}
+
+func (fs *FileStore) Load(id string) (*StateMxnSnapshot, error) {
+	file, err := fs.filepath(id)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("id '%s': %w", id, ErrSnapshotNotFound)
+	}
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &StateMxnSnapshot{}
+	if err := json.Unmarshal(b, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// maximum length of the id, so that its filename (hex(id) + ".json.tmp") fits in the 255 bytes allowed by most filesystems
+const fileStoreMaxIdLen = 120
+
+func (fs *FileStore) filepath(id string) (string, error) {
+	if len(id) > fileStoreMaxIdLen {
+		return "", fmt.Errorf("id '%s' is too long for a FileStore (max %d bytes)", id, fileStoreMaxIdLen)
+	}
+	return filepath.Join(fs.dir, hex.EncodeToString([]byte(id))+".json"), nil
+}
+
+// IdOfFilename returns the id of a snapshot file of the FileStore (ex: from a directory listing of its dir)
+func (fs *FileStore) IdOfFilename(filename string) (string, error) {
+	encodedId := strings.TrimSuffix(filepath.Base(filename), ".json")
+	id, err := hex.DecodeString(encodedId)
+	if err != nil {
+		return "", fmt.Errorf("'%s' is not a snapshot file of a FileStore: %w", filename, err)
+	}
+	return string(id), nil
+}
@@ -0,0 +1,149 @@
+package stateMxn
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Init -> Waiting, which expires after 72h with a durable timed-transition, unless Approved
+func newApprovalDefinition(t *testing.T) *StateMxnDefinition {
+	t.Helper()
+	initState := NewState("Init")
+	initState.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		smData["orderId"] = "order-1234"
+		outputs["amount"] = 42
+		return nil
+	})
+	waiting := NewState("Waiting")
+	waiting.AddDurableTimedTransition(72*time.Hour, "Expired")
+	def, err := NewStateMxnDefinition("Approval", map[string][]string{
+		"Init":     {"Waiting"},
+		"Waiting":  {"Approved", "Expired"},
+		"Approved": {},
+		"Expired":  {},
+	}, map[string]StateIfc{"Init": initState, "Waiting": waiting})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return def
+}
+
+func TestFileStoreRestoreAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	const id = "approval/1234"
+
+	// first process: runs until Waiting, saving a snapshot after every state-change
+	{
+		fs, err := NewFileStore(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		smg := newApprovalDefinition(t).NewInstance(id)
+		smg.SetClock(NewFakeClock(fakeClockStart))
+		smg.SetStore(fs, id)
+		if err := smg.Change("Init"); err != nil {
+			t.Fatal(err)
+		}
+		if err := smg.Change("Waiting"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// second process, 1h later: a fresh definition and instance, restored from the same dir
+	fs, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fc := NewFakeClock(fakeClockStart.Add(time.Hour))
+	smg := newApprovalDefinition(t).NewInstance(id)
+	smg.SetClock(fc)
+	smg.SetStore(fs, id)
+	if err := smg.RestoreFromStore(); err != nil {
+		t.Fatal(err)
+	}
+
+	if is, _ := smg.Is("^Waiting$"); !is {
+		t.Fatalf("restored current state = %s - want Waiting", smg.GetCurrentState().GetName())
+	}
+	history := smg.GetHistoryOfStates()
+	if len(history) != 2 || history[0].GetName() != "Init" {
+		t.Fatalf("restored history:\n%s", history.DisplayStatesFlow())
+	}
+	if got := smg.GetData()["orderId"]; got != "order-1234" {
+		t.Errorf("restored smx.data[orderId] = %v", got)
+	}
+	// JSON-decoded: the int became a float64
+	if got := history[0].GetOutputs()["amount"]; got != float64(42) {
+		t.Errorf("restored Init outputs[amount] = %#v", got)
+	}
+
+	// the durable timer was re-armed with its original deadline: 72h after entering Waiting
+	fc.Advance(70 * time.Hour)
+	if is, _ := smg.Is("^Waiting$"); !is {
+		t.Fatalf("changed into %s before the deadline", smg.GetCurrentState().GetName())
+	}
+	fc.Advance(time.Hour)
+	if is, _ := smg.Is("^Expired$"); !is {
+		t.Fatalf("current state = %s - want Expired", smg.GetCurrentState().GetName())
+	}
+
+	// and the expiration was also saved
+	restarted := newApprovalDefinition(t).NewInstance(id)
+	restarted.SetStore(fs, id)
+	if err := restarted.RestoreFromStore(); err != nil {
+		t.Fatal(err)
+	}
+	if is, _ := restarted.Is("^Expired$"); !is {
+		t.Errorf("saved current state = %s - want Expired", restarted.GetCurrentState().GetName())
+	}
+}
+
+func TestFileStoreIdsDoNotCollide(t *testing.T) {
+	fs, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// with a lossy encoding (ex: non-alphanumerics replaced by "_") or in case-insensitive filesystems, these would share a file
+	ids := []string{"a/b", "a_b", "a b", "A_B", "../a_b", ""}
+	for i, id := range ids {
+		if err := fs.Save(id, &StateMxnSnapshot{SmxName: "smx" + string(rune('0'+i))}); err != nil {
+			t.Fatalf("Save(%q): %s", id, err)
+		}
+	}
+	for i, id := range ids {
+		snapshot, err := fs.Load(id)
+		if err != nil {
+			t.Fatalf("Load(%q): %s", id, err)
+		}
+		if want := "smx" + string(rune('0'+i)); snapshot.SmxName != want {
+			t.Errorf("Load(%q) = snapshot of %s - want %s", id, snapshot.SmxName, want)
+		}
+	}
+
+	entries, err := os.ReadDir(fs.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(ids) {
+		t.Errorf("%d files for %d ids", len(entries), len(ids))
+	}
+	for _, entry := range entries {
+		id, err := fs.IdOfFilename(entry.Name())
+		if err != nil {
+			t.Errorf("IdOfFilename(%q): %s", entry.Name(), err)
+		}
+		if _, err := fs.Load(id); err != nil {
+			t.Errorf("Load(IdOfFilename(%q)): %s", entry.Name(), err)
+		}
+	}
+
+	if _, err := fs.Load("unknown"); !errors.Is(err, ErrSnapshotNotFound) {
+		t.Errorf("Load(unknown) = %v - want ErrSnapshotNotFound", err)
+	}
+	if err := fs.Save(strings.Repeat("x", fileStoreMaxIdLen+1), &StateMxnSnapshot{}); err == nil {
+		t.Error("Save() of a too long id did not fail")
+	}
+}
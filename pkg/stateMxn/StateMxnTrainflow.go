@@ -27,75 +27,101 @@ type TrainMinistate struct {
 }
 
 func NewStateMxnTrainFlow(smxName string, trainOfMinistates []TrainMinistate) (*StateMxnTrainflow, error) {
+	def, err := NewStateMxnTrainflowDefinition(smxName, trainOfMinistates)
+	if err != nil {
+		return nil, err
+	}
+	return def.NewInstance(smxName), nil
+}
 
-	// create smtf
-	var smtf *StateMxnTrainflow
-	{
-		var transitionsMap map[string][]string
-		{
-			/*
-				transitionsMap := map[string][]string{
-					"Init":    {"Running", "FinishedNok"},
-					"Running": {"FinishedOk", "FinishedNok"},
-				}
-			*/
+// StateMxnTrainflowDefinition is the StateMxnDefinition of StateMxnTrainflow instances
+type StateMxnTrainflowDefinition struct {
+	*StateMxnSimpleflowDefinition
+	trainOfMinistates []TrainMinistate
+}
 
-			var statesNames []string
-			{
-				for _, a_ministate := range trainOfMinistates {
-					a_stateName := a_ministate.StateName
-					statesNames = append(statesNames, a_stateName)
-				}
-			}
+func NewStateMxnTrainflowDefinition(smxName string, trainOfMinistates []TrainMinistate) (*StateMxnTrainflowDefinition, error) {
+	if len(trainOfMinistates) == 0 {
+		return nil, fmt.Errorf("smachine '%s': trainOfMinistates is empty", smxName)
+	}
 
-			for i, i_stateName := range statesNames {
-				curState := i_stateName
-				nextState := ""
-				{
-					if i < len(statesNames)-1 {
-						nextState = statesNames[i+1]
-					} else {
-						nextState = "FinishedOk"
-					}
-				}
-				transitionsMap[curState] = []string{nextState, "FinishedNok"}
+	transitionsMap := make(map[string][]string)
+	{
+		/*
+			transitionsMap := map[string][]string{
+				"Init":    {"Running", "FinishedNok"},
+				"Running": {"FinishedOk", "FinishedNok"},
 			}
-		}
+		*/
 
-		var precreatedStates map[string]StateIfc
+		var statesNames []string
 		{
-			/*
-				var smxInnerRunningState *stateMxn.State
-				{
-					smxInnerRunningState = stateMxn.NewState("Running")
-					smxInnerRunningState.AddHandlerExec(func(inputs stateMxn.StateInputs, outputs stateMxn.StateOutputs, stateData stateMxn.StateData, smachineData stateMxn.StateMxnData) error {
-						return fmt.Errorf("simulating error from smxInnerRunningState, to change state to FinishedNok")
-						// return nil
-					})
-				}
-				precreatedStates := map[string]stateMxn.StateIfc{
-					smxInnerRunningState.GetName(): smxInnerRunningState,
-				}
-			*/
 			for _, a_ministate := range trainOfMinistates {
 				a_stateName := a_ministate.StateName
-				a_stateHandler := a_ministate.HandlerFunc
-				a_state := NewState(a_stateName)
-				a_state.AddHandlerExec(a_stateHandler)
-				precreatedStates[a_stateName] = a_state
+				statesNames = append(statesNames, a_stateName)
 			}
 		}
 
-		smsf, err := NewStateMxnSimpleFlow(smxName, transitionsMap, precreatedStates)
-		smtf := &StateMxnTrainflow{
-			StateMxnSimpleflow: smsf,
-			trainOfMinistates:  trainOfMinistates,
+		for i, i_stateName := range statesNames {
+			curState := i_stateName
+			nextState := ""
+			{
+				if i < len(statesNames)-1 {
+					nextState = statesNames[i+1]
+				} else {
+					nextState = "FinishedOk"
+				}
+			}
+			transitionsMap[curState] = []string{nextState, "FinishedNok"}
 		}
-		if err != nil {
-			return smtf, err
+	}
+
+	precreatedStates := make(map[string]StateIfc)
+	{
+		/*
+			var smxInnerRunningState *stateMxn.State
+			{
+				smxInnerRunningState = stateMxn.NewState("Running")
+				smxInnerRunningState.AddHandlerExec(func(inputs stateMxn.StateInputs, outputs stateMxn.StateOutputs, stateData stateMxn.StateData, smachineData stateMxn.StateMxnData) error {
+					return fmt.Errorf("simulating error from smxInnerRunningState, to change state to FinishedNok")
+					// return nil
+				})
+			}
+			precreatedStates := map[string]stateMxn.StateIfc{
+				smxInnerRunningState.GetName(): smxInnerRunningState,
+			}
+		*/
+		for _, a_ministate := range trainOfMinistates {
+			a_stateName := a_ministate.StateName
+			a_stateHandler := a_ministate.HandlerFunc
+			a_state := NewState(a_stateName)
+			a_state.AddHandlerExec(a_stateHandler)
+			precreatedStates[a_stateName] = a_state
 		}
-	} // ATP: smtf is created and ready to be used
-	return smtf, nil
+	}
+
+	sfDef, err := NewStateMxnSimpleflowDefinitionWithOpts(smxName, transitionsMap, precreatedStates, &StateMxnDefinitionOpts{
+		InitialStates:     []string{trainOfMinistates[0].StateName},
+		FinalStates:       []string{"FinishedOk", "FinishedNok"},
+		FailedFinalStates: []string{"FinishedNok"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &StateMxnTrainflowDefinition{
+		StateMxnSimpleflowDefinition: sfDef,
+		trainOfMinistates:            append([]TrainMinistate{}, trainOfMinistates...),
+	}, nil
+}
+
+// NewInstance creates a new StateMxnTrainflow from the definition, identified by id
+func (def *StateMxnTrainflowDefinition) NewInstance(id string) *StateMxnTrainflow {
+	smtf := &StateMxnTrainflow{
+		StateMxnSimpleflow: def.StateMxnSimpleflowDefinition.NewInstance(id),
+		trainOfMinistates:  def.trainOfMinistates,
+	}
+	smtf.StateMxnGeneric.restrictChange = smtf.restrictChange
+	return smtf
 }
 
 func (smtf *StateMxnTrainflow) ChangeToInitialStateAndAutoprogressToOtherStates() error {
@@ -103,5 +129,14 @@ func (smtf *StateMxnTrainflow) ChangeToInitialStateAndAutoprogressToOtherStates(
 }
 
 func (smtf *StateMxnTrainflow) Change(stateName string) error {
-	return fmt.Errorf("Change() method is not allowed for StateMxnTrainflow. Use ChangeToInitialStateAndAutoprogressToOtherStates() instead")
+	return smtf.restrictChange(stateName)
+}
+
+// Returns the error of smtf.Change(), which is also returned for the state-changes requested with smtf.Send() in actor-mode
+func (smtf *StateMxnTrainflow) restrictChange(stateName string) error {
+	return fmt.Errorf("%w: Change() method is not allowed for StateMxnTrainflow. Use ChangeToInitialStateAndAutoprogressToOtherStates() instead", ErrChangeNotAllowed)
+}
+
+func (smtf *StateMxnTrainflow) GoTo(targetStateName string, opts *GoToOpts) (*GoToResult, error) {
+	return &GoToResult{}, fmt.Errorf("%w: GoTo() method is not allowed for StateMxnTrainflow. Use ChangeToInitialStateAndAutoprogressToOtherStates() instead", ErrChangeNotAllowed)
 }
@@ -0,0 +1,105 @@
+package stateMxn
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/*
+StateSchema declares the keys of the inputs (or outputs) of a state, and their types: map[<key>]<type>.
+A nil type means that the key must exist, but can have any type (or be nil).
+
+Declare it with state.RequireInput() and state.PromiseOutput(). On activation, the state verifies:
+  - the inputs against its inputs-schema, before the begin-handlers (failing like a begin-handler, so the end-handlers still run)
+  - the outputs against its outputs-schema, after the exec-handlers (if they did not fail)
+
+A failed verification returns a *SchemaError (see errors.As()).
+
+Keys not declared in the schema are not verified. The types are verified with reflect AssignableTo(), so interface-types can be used:
+
+	state.RequireInput("fromAlpha", reflect.TypeOf(""))
+	state.RequireInput("cause", reflect.TypeOf((*error)(nil)).Elem())
+	state.PromiseOutput("fromBeta", reflect.TypeOf(0))
+*/
+type StateSchema map[string]reflect.Type
+
+// Returns an error if m does not contain all keys of ss, with values of the declared types.
+// mapName and stateName are used in the error message
+func (ss StateSchema) validate(m map[string]interface{}, mapName string, stateName string) error {
+	var problems []string
+	for _, key := range ss.sortedKeys() {
+		typ := ss[key]
+		value, ok := m[key]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s['%s'] is missing", mapName, key))
+			continue
+		}
+		if typ == nil {
+			continue
+		}
+		if value == nil {
+			switch typ.Kind() {
+			case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan:
+				continue
+			}
+			problems = append(problems, fmt.Sprintf("%s['%s'] is nil, expected type %s", mapName, key, typ))
+			continue
+		}
+		if !reflect.TypeOf(value).AssignableTo(typ) {
+			problems = append(problems, fmt.Sprintf("%s['%s'] has type %T, expected type %s", mapName, key, value, typ))
+		}
+	}
+	if len(problems) > 0 {
+		return &SchemaError{StateName: stateName, MapName: mapName, Problems: problems}
+	}
+	return nil
+}
+
+// SchemaError is the error of a state whose inputs (or outputs) do not match its inputs-schema (or outputs-schema)
+type SchemaError struct {
+	StateName string
+	MapName   string   // "inputs" or "outputs"
+	Problems  []string // one per key of the schema not matched, sorted by key
+}
+
+func (se *SchemaError) Error() string {
+	return fmt.Sprintf("state '%s' schema violation: %s", se.StateName, strings.Join(se.Problems, "; "))
+}
+
+func (ss StateSchema) sortedKeys() []string {
+	keys := make([]string, 0, len(ss))
+	for key := range ss {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String returns "key1 (type1), key2 (type2)", sorted by key. Nil types are shown as "any"
+func (ss StateSchema) String() string {
+	var strs []string
+	for _, key := range ss.sortedKeys() {
+		strs = append(strs, key+" ("+schemaTypeString(ss[key])+")")
+	}
+	return strings.Join(strs, ", ")
+}
+
+func schemaTypeString(typ reflect.Type) string {
+	if typ == nil {
+		return "any"
+	}
+	return typ.String()
+}
+
+func (ss StateSchema) copy() StateSchema {
+	if ss == nil {
+		return nil
+	}
+	ssCopy := make(StateSchema, len(ss))
+	for k, v := range ss {
+		ssCopy[k] = v
+	}
+	return ssCopy
+}
@@ -0,0 +1,162 @@
+package stateMxn
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Returns a smachine Alpha -> Beta, where Alpha outputs alphaOutputs and Beta is the given state.
+// The phases run by Beta are appended to *betaPhases, and the phases it failed to *failedPhases
+func newSchemaSmx(t *testing.T, alphaOutputs StateOutputs, beta *State, betaPhases *[]HandlerPhase, failedPhases *[]HandlerPhase) *StateMxnGeneric {
+	t.Helper()
+	alpha := NewState("Alpha")
+	alpha.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+		for k, v := range alphaOutputs {
+			outputs[k] = v
+		}
+		return nil
+	})
+	for _, phase := range []HandlerPhase{HandlerPhaseBegin, HandlerPhaseExec, HandlerPhaseEnd} {
+		phase := phase
+		handler := func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+			*betaPhases = append(*betaPhases, phase)
+			return nil
+		}
+		switch phase {
+		case HandlerPhaseBegin:
+			beta.AddHandlerBegin(handler)
+		case HandlerPhaseExec:
+			beta.AddHandlerExec(handler)
+		case HandlerPhaseEnd:
+			beta.AddHandlerEnd(handler)
+		}
+	}
+	beta.AddHandlerOnError(func(phase HandlerPhase, err error, inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+		*failedPhases = append(*failedPhases, phase)
+		return nil
+	})
+	smg, err := NewStateMxnGeneric("schemaSmx", map[string][]string{
+		"Alpha": {"Beta"},
+	}, map[string]StateIfc{"Alpha": alpha, "Beta": beta})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := smg.Change("Alpha"); err != nil {
+		t.Fatal(err)
+	}
+	return smg
+}
+
+func TestStateSchemaRejectsInputs(t *testing.T) {
+	for name, tc := range map[string]struct {
+		alphaOutputs StateOutputs
+		wantProblem  string
+	}{
+		"missing input": {StateOutputs{"other": "x"}, "inputs['fromAlpha'] is missing"},
+		"wrong type":    {StateOutputs{"fromAlpha": 1}, "inputs['fromAlpha'] has type int, expected type string"},
+		"nil value":     {StateOutputs{"fromAlpha": nil}, "inputs['fromAlpha'] is nil, expected type string"},
+	} {
+		beta := NewState("Beta")
+		beta.RequireInput("fromAlpha", reflect.TypeOf(""))
+		var betaPhases, failedPhases []HandlerPhase
+		smg := newSchemaSmx(t, tc.alphaOutputs, beta, &betaPhases, &failedPhases)
+
+		err := smg.Change("Beta")
+		var schemaErr *SchemaError
+		if !errors.As(err, &schemaErr) {
+			t.Fatalf("%s: Change() = %v - want a *SchemaError", name, err)
+		}
+		if schemaErr.StateName != "Beta" || schemaErr.MapName != "inputs" || !reflect.DeepEqual(schemaErr.Problems, []string{tc.wantProblem}) {
+			t.Errorf("%s: SchemaError = %+v", name, schemaErr)
+		}
+		if !strings.Contains(err.Error(), "state 'Beta' schema violation: "+tc.wantProblem) {
+			t.Errorf("%s: error message = %q", name, err.Error())
+		}
+		// the inputs are verified before the begin-handlers, failing like a begin-handler: only the end-handlers run
+		if !reflect.DeepEqual(betaPhases, []HandlerPhase{HandlerPhaseEnd}) {
+			t.Errorf("%s: phases run = %v - want only the end-phase", name, betaPhases)
+		}
+		if !reflect.DeepEqual(failedPhases, []HandlerPhase{HandlerPhaseBegin}) {
+			t.Errorf("%s: failed phases = %v - want the begin-phase", name, failedPhases)
+		}
+	}
+}
+
+func TestStateSchemaAcceptsInputs(t *testing.T) {
+	beta := NewState("Beta")
+	beta.RequireInput("fromAlpha", reflect.TypeOf(""))
+	beta.RequireInput("cause", reflect.TypeOf((*error)(nil)).Elem())
+	beta.RequireInput("anything", nil)
+	var betaPhases, failedPhases []HandlerPhase
+	smg := newSchemaSmx(t, StateOutputs{"fromAlpha": "a", "cause": nil, "anything": 3, "undeclared": true}, beta, &betaPhases, &failedPhases)
+
+	if err := smg.Change("Beta"); err != nil {
+		t.Fatalf("Change() = %v", err)
+	}
+	if len(betaPhases) != 3 || len(failedPhases) != 0 {
+		t.Errorf("phases run = %v, failed phases = %v", betaPhases, failedPhases)
+	}
+	if got, want := beta.GetInputsSchema().String(), "anything (any), cause (error), fromAlpha (string)"; got != want {
+		t.Errorf("GetInputsSchema().String() = %q - want %q", got, want)
+	}
+}
+
+func TestStateSchemaVerifiesOutputsAfterExec(t *testing.T) {
+	for name, tc := range map[string]struct {
+		fromBeta    interface{}
+		wantProblem string
+	}{
+		"wrong type":     {"x", "outputs['fromBeta'] has type string, expected type int"},
+		"promise is met": {7, ""},
+	} {
+		beta := NewState("Beta")
+		beta.PromiseOutput("fromBeta", reflect.TypeOf(0))
+		beta.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+			outputs["fromBeta"] = tc.fromBeta
+			return nil
+		})
+		var betaPhases, failedPhases []HandlerPhase
+		smg := newSchemaSmx(t, nil, beta, &betaPhases, &failedPhases)
+
+		err := smg.Change("Beta")
+		if tc.wantProblem == "" {
+			if err != nil {
+				t.Errorf("%s: Change() = %v", name, err)
+			}
+			continue
+		}
+		var schemaErr *SchemaError
+		if !errors.As(err, &schemaErr) {
+			t.Fatalf("%s: Change() = %v - want a *SchemaError", name, err)
+		}
+		if schemaErr.MapName != "outputs" || !reflect.DeepEqual(schemaErr.Problems, []string{tc.wantProblem}) {
+			t.Errorf("%s: SchemaError = %+v", name, schemaErr)
+		}
+		// the outputs are verified after the exec-handlers, failing like an exec-handler: the end-handlers still run
+		if !reflect.DeepEqual(betaPhases, []HandlerPhase{HandlerPhaseBegin, HandlerPhaseExec, HandlerPhaseEnd}) {
+			t.Errorf("%s: phases run = %v", name, betaPhases)
+		}
+		if !reflect.DeepEqual(failedPhases, []HandlerPhase{HandlerPhaseExec}) {
+			t.Errorf("%s: failed phases = %v - want the exec-phase", name, failedPhases)
+		}
+	}
+}
+
+func TestStateSchemaSkipsOutputsWhenExecFails(t *testing.T) {
+	beta := NewState("Beta")
+	beta.PromiseOutput("fromBeta", reflect.TypeOf(0))
+	errExec := errors.New("exec failed")
+	beta.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+		return errExec
+	})
+	var betaPhases, failedPhases []HandlerPhase
+	smg := newSchemaSmx(t, nil, beta, &betaPhases, &failedPhases)
+
+	err := smg.Change("Beta")
+	var schemaErr *SchemaError
+	if !errors.Is(err, errExec) || errors.As(err, &schemaErr) {
+		t.Errorf("Change() = %v - want only the exec-handler error", err)
+	}
+}
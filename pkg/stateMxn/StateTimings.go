@@ -0,0 +1,88 @@
+package stateMxn
+
+import (
+	"strings"
+	"time"
+)
+
+/*
+StateTimings is the timing breakdown of an activation of a state:
+
+  - TimeElapsed: the whole activation (as state.data["timeElapsed"])
+  - Phases: the duration of each phase (as state.data["timePhases"]). The begin-phase includes the inputs-schema verification, the
+    exec-phase the outputs-schema verification, and each phase includes the onError-handlers executed when it failed. A phase
+    not executed (ex: exec, when begin failed) is not present
+  - Handlers: the duration and result of each executed handler (as state.data["handlers"], see HandlerRecord)
+
+After each activation, the StateTimings are exported into the MetricsExporter of the smachine (see smg.SetMetricsExporter()).
+They are also shown in DisplayStatesFlow() and in the PlantUml diagrams
+*/
+type StateTimings struct {
+	SmxName     string
+	StateName   string
+	NestingPath []string // see HandlerError.NestingPath
+	TimeStart   time.Time
+	TimeElapsed time.Duration
+	Phases      map[HandlerPhase]time.Duration
+	Handlers    []HandlerRecord
+	Failed      bool // the activation returned an error
+}
+
+// MetricsExporter receives the StateTimings of each activation of the states of a smachine. See smg.SetMetricsExporter()
+//
+// NOTE: ExportStateTimings() is called synchronously from the state-change, so it should be fast and must not change the state
+// of the smachine
+type MetricsExporter interface {
+	ExportStateTimings(timings StateTimings)
+}
+
+// MetricsExporterFunc is a func used as a MetricsExporter
+type MetricsExporterFunc func(timings StateTimings)
+
+func (f MetricsExporterFunc) ExportStateTimings(timings StateTimings) {
+	f(timings)
+}
+
+// SetMetricsExporter sets the MetricsExporter that receives the StateTimings of each activation of the states of smg.
+// Its also inherited by any enclosedSmx without its own MetricsExporter. exporter can be nil, to disable it
+func (smg *StateMxnGeneric) SetMetricsExporter(exporter MetricsExporter) {
+	smg.metricsExporter = exporter
+}
+
+// Returns the MetricsExporter of smg, or the inherited one from the outter smachines, or nil
+func (smg *StateMxnGeneric) getMetricsExporter() MetricsExporter {
+	if smg.metricsExporter != nil {
+		return smg.metricsExporter
+	}
+	return smg.inheritedMetricsExporter
+}
+
+// Exports the StateTimings of the just-activated state into the MetricsExporter (if any)
+func (smg *StateMxnGeneric) exportStateTimings(state StateIfc, activationErr error) {
+	exporter := smg.getMetricsExporter()
+	if exporter == nil {
+		return
+	}
+	timings := StateTimings{
+		SmxName:     smg.GetName(),
+		StateName:   state.GetName(),
+		NestingPath: append(smg.getNestingPath(), state.GetName()),
+		Failed:      activationErr != nil,
+	}
+	timings.TimeStart, _ = state.GetData()["timeStart"].(time.Time)
+	timings.TimeElapsed, _ = state.GetData()["timeElapsed"].(time.Duration)
+	timings.Phases, _ = state.GetData()["timePhases"].(map[HandlerPhase]time.Duration)
+	timings.Handlers, _ = state.GetData()["handlers"].([]HandlerRecord)
+	exporter.ExportStateTimings(timings)
+}
+
+// Ex: "begin 1.2µs, exec 3ms, end 800ns"
+func timePhasesString(timePhases map[HandlerPhase]time.Duration) string {
+	var strs []string
+	for _, phase := range []HandlerPhase{HandlerPhaseBegin, HandlerPhaseExec, HandlerPhaseEnd} {
+		if d, ok := timePhases[phase]; ok {
+			strs = append(strs, string(phase)+" "+d.String())
+		}
+	}
+	return strings.Join(strs, ", ")
+}
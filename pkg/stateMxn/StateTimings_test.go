@@ -0,0 +1,112 @@
+package stateMxn
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Returns a handler that advances fc by d, and returns err
+func advancingHandler(fc *FakeClock, d time.Duration, err error) StateHandler {
+	return func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		fc.Advance(d)
+		return err
+	}
+}
+
+func TestStateTimingsAreExported(t *testing.T) {
+	errEnd := errors.New("end fails")
+	fc := NewFakeClock(fakeClockStart)
+	running := NewState("Running")
+	running.AddHandlerBegin(advancingHandler(fc, 1*time.Second, nil))
+	running.AddHandlerExec(advancingHandler(fc, 2*time.Second, nil))
+	running.AddHandlerExecNamed("slow", advancingHandler(fc, 3*time.Second, nil))
+	running.AddHandlerEnd(advancingHandler(fc, 4*time.Second, errEnd))
+	smg, err := NewStateMxnGeneric("smx", map[string][]string{
+		"Init":    {"Running"},
+		"Running": {"Finished"},
+	}, map[string]StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smg.SetClock(fc)
+	var exported []StateTimings
+	smg.SetMetricsExporter(MetricsExporterFunc(func(timings StateTimings) {
+		exported = append(exported, timings)
+	}))
+
+	if err := smg.Change("Init"); err != nil {
+		t.Fatal(err)
+	}
+	fc.Advance(time.Minute)
+	if err := smg.Change("Running"); !errors.Is(err, errEnd) {
+		t.Fatalf("Change(Running) = %v - want errEnd", err)
+	}
+
+	if len(exported) != 2 || exported[0].StateName != "Init" || exported[0].Failed || exported[1].StateName != "Running" {
+		t.Fatalf("exported %+v - want the timings of Init (ok) and Running", exported)
+	}
+	timings := exported[1]
+	if timings.SmxName != "smx" || !reflect.DeepEqual(timings.NestingPath, []string{"smx", "Running"}) || !timings.Failed {
+		t.Errorf("timings = %+v - want the failed activation of smx/Running", timings)
+	}
+	if !timings.TimeStart.Equal(fakeClockStart.Add(time.Minute)) || timings.TimeElapsed != 10*time.Second {
+		t.Errorf("TimeStart = %s, TimeElapsed = %s - want %s, 10s", timings.TimeStart, timings.TimeElapsed, fakeClockStart.Add(time.Minute))
+	}
+	wantPhases := map[HandlerPhase]time.Duration{
+		HandlerPhaseBegin: 1 * time.Second,
+		HandlerPhaseExec:  5 * time.Second,
+		HandlerPhaseEnd:   4 * time.Second,
+	}
+	if !reflect.DeepEqual(timings.Phases, wantPhases) {
+		t.Errorf("Phases = %v - want %v", timings.Phases, wantPhases)
+	}
+	wantHandlers := []struct {
+		phase    HandlerPhase
+		name     string
+		duration time.Duration
+		failed   bool
+	}{
+		{HandlerPhaseBegin, "", 1 * time.Second, false},
+		{HandlerPhaseExec, "", 2 * time.Second, false},
+		{HandlerPhaseExec, "slow", 3 * time.Second, false},
+		{HandlerPhaseEnd, "", 4 * time.Second, true},
+	}
+	if len(timings.Handlers) != len(wantHandlers) {
+		t.Fatalf("Handlers = %v - want %d records", timings.Handlers, len(wantHandlers))
+	}
+	for i, want := range wantHandlers {
+		hr := timings.Handlers[i]
+		if hr.Phase != want.phase || hr.Name != want.name || hr.Duration != want.duration || (hr.Err != nil) != want.failed {
+			t.Errorf("Handlers[%d] = %s - want %s '%s' %s (failed: %v)", i, hr, want.phase, want.name, want.duration, want.failed)
+		}
+	}
+}
+
+func TestStateTimingsSkipTheExecPhaseWhenBeginFails(t *testing.T) {
+	fc := NewFakeClock(fakeClockStart)
+	running := NewState("Running")
+	running.AddHandlerBegin(advancingHandler(fc, 1*time.Second, errors.New("begin fails")))
+	running.AddHandlerExec(advancingHandler(fc, 2*time.Second, nil))
+	smg, err := NewStateMxnGeneric("smx", map[string][]string{"Running": {"Finished"}}, map[string]StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	smg.SetClock(fc)
+	var exported []StateTimings
+	smg.SetMetricsExporter(MetricsExporterFunc(func(timings StateTimings) {
+		exported = append(exported, timings)
+	}))
+	_ = smg.Change("Running")
+
+	if len(exported) != 1 || !exported[0].Failed {
+		t.Fatalf("exported %+v - want the failed activation of Running", exported)
+	}
+	if _, ok := exported[0].Phases[HandlerPhaseExec]; ok || exported[0].Phases[HandlerPhaseBegin] != time.Second {
+		t.Errorf("Phases = %v - want begin 1s, and no exec", exported[0].Phases)
+	}
+	if len(exported[0].Handlers) != 1 {
+		t.Errorf("Handlers = %v - want only the begin-handler", exported[0].Handlers)
+	}
+}
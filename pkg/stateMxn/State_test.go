@@ -0,0 +1,105 @@
+package stateMxn
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDisplayStatesFlowFromHandler(t *testing.T) {
+	var smg *StateMxnGeneric
+	var statesFlow string
+	running := NewState("Running")
+	running.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		statesFlow = smg.GetHistoryOfStates().DisplayStatesFlow()
+		return detailedError{details: []int{1}}
+	})
+	var err error
+	smg, err = NewStateMxnGeneric("smx", map[string][]string{"Init": {"Running"}}, map[string]StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = smg.Change("Init")
+	if err := smg.Change("Running"); err == nil {
+		t.Fatal("Change(Running) did not fail")
+	}
+	if !strings.Contains(statesFlow, "Running\t[-]") {
+		t.Errorf("DisplayStatesFlow() from the handler:\n%s", statesFlow)
+	}
+	if len(smg.GetErrors()) != 1 {
+		t.Errorf("smg.GetErrors() = %v - want 1 error", smg.GetErrors())
+	}
+}
+
+// Returns a handler that appends name to *ran, and returns err
+func recordingHandler(ran *[]string, name string, err error) StateHandler {
+	return func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		*ran = append(*ran, name)
+		return err
+	}
+}
+
+func TestBeginErrorSkipsExecButRunsEnd(t *testing.T) {
+	errBegin := errors.New("begin fails")
+	var ran []string
+	var onErrors []string
+	running := NewState("Running")
+	running.AddHandlerBegin(recordingHandler(&ran, "begin", errBegin))
+	running.AddHandlerExec(recordingHandler(&ran, "exec", nil))
+	running.AddHandlerEnd(recordingHandler(&ran, "end", nil))
+	running.AddHandlerOnError(func(phase HandlerPhase, err error, inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+		if !errors.Is(err, errBegin) {
+			t.Errorf("onError-handler got error %v - want errBegin", err)
+		}
+		onErrors = append(onErrors, string(phase))
+		return nil
+	})
+	smg, err := NewStateMxnGeneric("smx", map[string][]string{"Running": {"Finished"}}, map[string]StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := smg.Change("Running"); !errors.Is(err, errBegin) {
+		t.Fatalf("Change() = %v - want errBegin", err)
+	}
+	if want := []string{"begin", "end"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v - want %v", ran, want)
+	}
+	if want := []string{string(HandlerPhaseBegin)}; !reflect.DeepEqual(onErrors, want) {
+		t.Errorf("onError-handler got phases %v - want %v", onErrors, want)
+	}
+}
+
+func TestAllEndHandlersRunAfterOneFails(t *testing.T) {
+	errEnd := errors.New("end fails")
+	var ran []string
+	type onErrorCall struct {
+		phase HandlerPhase
+		err   error
+	}
+	var onErrorCalls []onErrorCall
+	running := NewState("Running")
+	running.AddHandlerExec(recordingHandler(&ran, "exec", nil))
+	// the end-handlers are prepended, so end0 (added last) runs first
+	running.AddHandlerEnd(recordingHandler(&ran, "end1", nil))
+	running.AddHandlerEnd(recordingHandler(&ran, "end0", errEnd))
+	running.AddHandlerOnError(func(phase HandlerPhase, err error, inputs StateInputs, outputs StateOutputs, stateData StateData, smachineData StateMxnData) error {
+		onErrorCalls = append(onErrorCalls, onErrorCall{phase, err})
+		return nil
+	})
+	smg, err := NewStateMxnGeneric("smx", map[string][]string{"Running": {"Finished"}}, map[string]StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = smg.Change("Running")
+	if want := []string{"exec", "end0", "end1"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %v - want %v", ran, want)
+	}
+	var he *HandlerError
+	if !errors.As(err, &he) || he.Phase != HandlerPhaseEnd || he.HandlerIndex != 0 || !errors.Is(err, errEnd) {
+		t.Errorf("Change() = %v - want the HandlerError of end-handler[0]", err)
+	}
+	if len(onErrorCalls) != 1 || onErrorCalls[0].phase != HandlerPhaseEnd || !errors.Is(onErrorCalls[0].err, errEnd) {
+		t.Errorf("onError-handler calls = %v - want 1 call with the end phase and errEnd", onErrorCalls)
+	}
+}
@@ -0,0 +1,184 @@
+package stateMxn
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+)
+
+/*
+TransitionCoverage accumulates, across many runs of smachines, which states and transitions (edges of the transitionsMap)
+were visited - so that its possible to know which parts of a transitionsMap were never exercised (ex: by a test suite)
+
+	tc := NewTransitionCoverage()
+	for _, tcase := range testcases {
+		smx, _ := NewStateMxnGeneric("Example", transitionsMap, nil)
+		tc.Attach(smx)
+		... run smx ...
+	}
+	fmt.Println(tc.Report())
+	_, url := tc.GetPlantUmlTransitionMap("Example")
+
+The coverage is kept per smachine-name, so all smachines with the same name accumulate into the same coverage.
+An attached smachine will also attach any of its enclosedSmx, when the state that encloses them is activated.
+
+Its safe for concurrent use by multiple smachines
+*/
+type TransitionCoverage struct {
+	mu sync.Mutex
+
+	// smxCoverages[<smxName>]
+	smxCoverages map[string]*smxCoverage
+}
+
+type smxCoverage struct {
+	transitionsMap map[string][]string
+
+	// visitedStates[<stateName>] = <count>
+	visitedStates map[string]int
+	// visitedEdges[<sourceStateName>][<destinationStateName>] = <count>
+	visitedEdges map[string]map[string]int
+}
+
+func NewTransitionCoverage() *TransitionCoverage {
+	return &TransitionCoverage{
+		smxCoverages: make(map[string]*smxCoverage),
+	}
+}
+
+// Attach makes smx (and its enclosedSmx) record into tc every state-change it does from now on.
+// smx must be a StateMxnGeneric (or embed one), otherwise its ignored
+func (tc *TransitionCoverage) Attach(smx StateMxnIfc) {
+	smg, ok := asStateMxnGeneric(smx)
+	if !ok {
+		return
+	}
+	smg.coverage = tc
+	tc.getSmxCoverage(smg)
+}
+
+// Returns the smxCoverage for smg.GetName(), creating it if needed
+func (tc *TransitionCoverage) getSmxCoverage(smg *StateMxnGeneric) *smxCoverage {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	smxc, ok := tc.smxCoverages[smg.GetName()]
+	if !ok {
+		smxc = &smxCoverage{
+			transitionsMap: smg.GetTransitionsMap(),
+			visitedStates:  make(map[string]int),
+			visitedEdges:   make(map[string]map[string]int),
+		}
+		tc.smxCoverages[smg.GetName()] = smxc
+	}
+	return smxc
+}
+
+// Called by smg on each state-change. sourceStateName is "" when changing to the initial state
+func (tc *TransitionCoverage) record(smg *StateMxnGeneric, sourceStateName string, destinationStateName string) {
+	smxc := tc.getSmxCoverage(smg)
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	smxc.visitedStates[destinationStateName]++
+	if sourceStateName == "" {
+		return
+	}
+	if smxc.visitedEdges[sourceStateName] == nil {
+		smxc.visitedEdges[sourceStateName] = make(map[string]int)
+	}
+	smxc.visitedEdges[sourceStateName][destinationStateName]++
+}
+
+// GetSmxNames returns the (sorted) names of the smachines with coverage
+func (tc *TransitionCoverage) GetSmxNames() []string {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	names := make([]string, 0, len(tc.smxCoverages))
+	for name := range tc.smxCoverages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetUncoveredStates returns the (sorted) states of the transitionsMap of smxName that were never visited
+func (tc *TransitionCoverage) GetUncoveredStates(smxName string) []string {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	smxc, ok := tc.smxCoverages[smxName]
+	if !ok {
+		return nil
+	}
+	var uncoveredStates []string
+	for _, stateName := range allStatenames(smxc.transitionsMap) {
+		if smxc.visitedStates[stateName] == 0 {
+			uncoveredStates = append(uncoveredStates, stateName)
+		}
+	}
+	return uncoveredStates
+}
+
+// GetUncoveredEdges returns the edges ([2]string{source, destination}) of the transitionsMap of smxName that were never visited
+func (tc *TransitionCoverage) GetUncoveredEdges(smxName string) [][2]string {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	smxc, ok := tc.smxCoverages[smxName]
+	if !ok {
+		return nil
+	}
+	var uncoveredEdges [][2]string
+	for _, source := range sortedKeys(smxc.transitionsMap) {
+		for _, destination := range smxc.transitionsMap[source] {
+			if smxc.visitedEdges[source][destination] == 0 {
+				uncoveredEdges = append(uncoveredEdges, [2]string{source, destination})
+			}
+		}
+	}
+	return uncoveredEdges
+}
+
+// Report returns a text report, per smachine, of the uncovered states and edges
+func (tc *TransitionCoverage) Report() string {
+	var str string
+	for _, smxName := range tc.GetSmxNames() {
+		uncoveredStates := tc.GetUncoveredStates(smxName)
+		uncoveredEdges := tc.GetUncoveredEdges(smxName)
+
+		tc.mu.Lock()
+		smxc := tc.smxCoverages[smxName]
+		nStates := len(allStatenames(smxc.transitionsMap))
+		nEdges := 0
+		for _, destinations := range smxc.transitionsMap {
+			nEdges += len(destinations)
+		}
+		tc.mu.Unlock()
+
+		str += "===== " + smxName + " =====\n"
+		str += "states covered: " + strconv.Itoa(nStates-len(uncoveredStates)) + "/" + strconv.Itoa(nStates) + "\n"
+		for _, stateName := range uncoveredStates {
+			str += "\tuncovered state: " + stateName + "\n"
+		}
+		str += "edges covered: " + strconv.Itoa(nEdges-len(uncoveredEdges)) + "/" + strconv.Itoa(nEdges) + "\n"
+		for _, edge := range uncoveredEdges {
+			str += "\tuncovered edge: " + edge[0] + " --> " + edge[1] + "\n"
+		}
+	}
+	return str
+}
+
+// GetPlantUmlTransitionMap returns the transitionsMap diagram of smxName, with covered edges in green and uncovered edges dashed red
+func (tc *TransitionCoverage) GetPlantUmlTransitionMap(smxName string) (tm_plantUmlText string, tm_plantUmlUrl string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	smxc, ok := tc.smxCoverages[smxName]
+	if !ok {
+		return plantUmlGen4TransitionsMap(map[string][]string{}, nil)
+	}
+	return plantUmlGen4TransitionsMap(smxc.transitionsMap, &plantUmlGen4TransitionsMapOpts{
+		arrowFunc: func(fromState string, toState string) string {
+			if smxc.visitedEdges[fromState][toState] > 0 {
+				return "-[#green,bold]->"
+			}
+			return "-[#red,dashed]->"
+		},
+	})
+}
@@ -0,0 +1,129 @@
+package stateMxn
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var coverageTransitionsMap = map[string][]string{
+	"Init":    {"Running", "FinishedNok"},
+	"Running": {"FinishedOk", "FinishedNok"},
+}
+
+func TestTransitionCoverageAccumulatesAcrossRuns(t *testing.T) {
+	tc := NewTransitionCoverage()
+	for _, path := range [][]string{
+		{"Init", "Running", "FinishedOk"},
+		{"Init", "Running", "FinishedOk"},
+		{"Init", "FinishedNok"},
+	} {
+		smg, err := NewStateMxnGeneric("Example", coverageTransitionsMap, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tc.Attach(smg)
+		for _, stateName := range path {
+			if err := smg.Change(stateName); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if got := tc.GetSmxNames(); !reflect.DeepEqual(got, []string{"Example"}) {
+		t.Errorf("GetSmxNames() = %v", got)
+	}
+	if got := tc.GetUncoveredStates("Example"); len(got) != 0 {
+		t.Errorf("GetUncoveredStates() = %v - want none", got)
+	}
+	if got, want := tc.GetUncoveredEdges("Example"), [][2]string{{"Running", "FinishedNok"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetUncoveredEdges() = %v - want %v", got, want)
+	}
+	tc.mu.Lock()
+	visits := tc.smxCoverages["Example"].visitedEdges["Init"]["Running"]
+	tc.mu.Unlock()
+	if visits != 2 {
+		t.Errorf("Init -> Running visited %d times - want 2", visits)
+	}
+
+	report := tc.Report()
+	for _, want := range []string{
+		"===== Example =====",
+		"states covered: 4/4",
+		"edges covered: 3/4",
+		"uncovered edge: Running --> FinishedNok",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Report() does not contain %q:\n%s", want, report)
+		}
+	}
+
+	plantUmlText, _ := tc.GetPlantUmlTransitionMap("Example")
+	for _, want := range []string{
+		"Init -[#green,bold]-> Running",
+		"Running -[#green,bold]-> FinishedOk",
+		"Running -[#red,dashed]-> FinishedNok",
+	} {
+		if !strings.Contains(plantUmlText, want) {
+			t.Errorf("GetPlantUmlTransitionMap() does not contain %q:\n%s", want, plantUmlText)
+		}
+	}
+}
+
+func TestTransitionCoverageUncovered(t *testing.T) {
+	tc := NewTransitionCoverage()
+	smg, err := NewStateMxnGeneric("Example", coverageTransitionsMap, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// changes done before Attach() are not recorded
+	_ = smg.Change("Init")
+	tc.Attach(smg)
+	_ = smg.Change("FinishedNok")
+
+	if got, want := tc.GetUncoveredStates("Example"), []string{"FinishedOk", "Init", "Running"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetUncoveredStates() = %v - want %v", got, want)
+	}
+	// the edge Init -> FinishedNok is recorded, even if Init was changed-into before Attach()
+	if got, want := tc.GetUncoveredEdges("Example"), [][2]string{{"Init", "Running"}, {"Running", "FinishedOk"}, {"Running", "FinishedNok"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetUncoveredEdges() = %v - want %v", got, want)
+	}
+	if got := tc.GetUncoveredStates("Unknown"); got != nil {
+		t.Errorf("GetUncoveredStates(Unknown) = %v - want nil", got)
+	}
+	if !strings.Contains(tc.Report(), "states covered: 1/4") {
+		t.Errorf("Report():\n%s", tc.Report())
+	}
+}
+
+func TestTransitionCoverageAttachesEnclosedSmx(t *testing.T) {
+	smxInner, err := NewStateMxnSimpleFlow("Inner", map[string][]string{
+		"InitInner": {"FinishedOk", "FinishedNok"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	smxOutter, err := NewStateMxnGeneric("Outter", map[string][]string{
+		"Init":      {"Enclosing"},
+		"Enclosing": {"Done"},
+	}, map[string]StateIfc{
+		"Enclosing": NewStateEnclosingSmxSimpleflow("Enclosing", smxInner, "InitInner"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tc := NewTransitionCoverage()
+	tc.Attach(smxOutter)
+	for _, stateName := range []string{"Init", "Enclosing", "Done"} {
+		if err := smxOutter.Change(stateName); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := tc.GetSmxNames(); !reflect.DeepEqual(got, []string{"Inner", "Outter"}) {
+		t.Fatalf("GetSmxNames() = %v - want the enclosedSmx attached too", got)
+	}
+	if got, want := tc.GetUncoveredEdges("Inner"), [][2]string{{"InitInner", "FinishedNok"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("GetUncoveredEdges(Inner) = %v - want %v", got, want)
+	}
+}
@@ -0,0 +1,315 @@
+package stateMxn
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/*
+TransitionsMapAnalysis is the result of a static analysis of a transitionsMap, from a given initial state.
+See AnalyzeTransitionsMap() and smg.Analyze()
+
+Final-states are the states without transitions (ie, that are not sources in the transitionsMap, or that have an empty list of destinations),
+or with smg.Analyze(), the declared final states (see StateMxnDefinitionOpts)
+
+The results are deterministic (states are sorted, and paths follow the order of the destinations in the transitionsMap), so they can be compared in tests
+*/
+type TransitionsMapAnalysis struct {
+	InitialStateName string
+	States           []string
+	FinalStates      []string
+
+	// PathsToFinalStates[<finalStateName>] contains every simple-path (without repeated states) from InitialStateName to finalStateName
+	// As their number can grow exponentially with the size of the transitionsMap, the enumeration stops after
+	// TransitionsMapAnalysisMaxPaths paths (or TransitionsMapAnalysisMaxSteps steps), setting PathsTruncated
+	PathsToFinalStates map[string][][]string
+	PathsTruncated     bool
+
+	// Cycles contains every elementary cycle, starting at its (alphabetically) smallest state. Ex: {"A", "B"} means A -> B -> A
+	// Like PathsToFinalStates, the enumeration is bounded, setting CyclesTruncated
+	Cycles          [][]string
+	CyclesTruncated bool
+
+	// DeadEndStates are non-final states from which no final-state is reachable
+	DeadEndStates []string
+
+	// UnreachableStates are states that can not be reached from InitialStateName
+	UnreachableStates []string
+
+	StronglyConnectedComponents [][]string
+
+	// expandErr - error expanding the patterns of the transitionsMap (see TransitionsMapPatterns.go), returned by tma.Validate()
+	expandErr error
+}
+
+// Limits of the enumeration of PathsToFinalStates and Cycles of a TransitionsMapAnalysis (each one separately):
+// at most TransitionsMapAnalysisMaxPaths paths (or cycles) are listed, and at most TransitionsMapAnalysisMaxSteps states visited
+const (
+	TransitionsMapAnalysisMaxPaths = 10000
+	TransitionsMapAnalysisMaxSteps = 1000000
+)
+
+// AnalyzeTransitionsMap performs a static analysis of transitionsMap, taking initialStateName as the initial state
+// The patterns of transitionsMap ("*" and regexps) are expanded before the analysis (see TransitionsMapPatterns.go)
+func AnalyzeTransitionsMap(transitionsMap map[string][]string, initialStateName string) *TransitionsMapAnalysis {
+	var expandErr error
+	if hasTransitionsMapPatterns(transitionsMap) {
+		var expanded map[string][]string
+		expanded, expandErr = expandTransitionsMap(transitionsMap)
+		if expandErr == nil {
+			transitionsMap = expanded
+		}
+	}
+	tma := analyzeTransitionsMap(transitionsMap, initialStateName, func(stateName string) bool {
+		return isFinalStateInTransitionsMap(transitionsMap, stateName)
+	})
+	tma.expandErr = expandErr
+	return tma
+}
+
+// isFinalState decides which states are final
+func analyzeTransitionsMap(transitionsMap map[string][]string, initialStateName string, isFinalState func(stateName string) bool) *TransitionsMapAnalysis {
+	tma := &TransitionsMapAnalysis{
+		InitialStateName:   initialStateName,
+		States:             allStatenames(transitionsMap),
+		PathsToFinalStates: make(map[string][][]string),
+	}
+
+	// FinalStates
+	for _, stateName := range tma.States {
+		if isFinalState(stateName) {
+			tma.FinalStates = append(tma.FinalStates, stateName)
+			tma.PathsToFinalStates[stateName] = [][]string{}
+		}
+	}
+
+	// PathsToFinalStates
+	{
+		var path []string
+		visited := make(map[string]bool)
+		nPaths, nSteps := 0, 0
+		var dfs func(stateName string)
+		dfs = func(stateName string) {
+			nSteps++
+			if nPaths >= TransitionsMapAnalysisMaxPaths || nSteps > TransitionsMapAnalysisMaxSteps {
+				tma.PathsTruncated = true
+				return
+			}
+			path = append(path, stateName)
+			visited[stateName] = true
+			if isFinalState(stateName) {
+				pathCopy := make([]string, len(path))
+				copy(pathCopy, path)
+				tma.PathsToFinalStates[stateName] = append(tma.PathsToFinalStates[stateName], pathCopy)
+				nPaths++
+			}
+			for _, nextStateName := range transitionsMap[stateName] {
+				if !visited[nextStateName] && !tma.PathsTruncated {
+					dfs(nextStateName)
+				}
+			}
+			visited[stateName] = false
+			path = path[:len(path)-1]
+		}
+		dfs(initialStateName)
+	}
+
+	// Cycles
+	{
+		// Each cycle is searched starting from its smallest state, only going through bigger states, so it is found only once
+		nSteps := 0
+		for _, startStateName := range tma.States {
+			var path []string
+			visited := make(map[string]bool)
+			var dfs func(stateName string)
+			dfs = func(stateName string) {
+				nSteps++
+				if len(tma.Cycles) >= TransitionsMapAnalysisMaxPaths || nSteps > TransitionsMapAnalysisMaxSteps {
+					tma.CyclesTruncated = true
+					return
+				}
+				path = append(path, stateName)
+				visited[stateName] = true
+				for _, nextStateName := range transitionsMap[stateName] {
+					if tma.CyclesTruncated {
+						break
+					}
+					if nextStateName == startStateName {
+						cycle := make([]string, len(path))
+						copy(cycle, path)
+						tma.Cycles = append(tma.Cycles, cycle)
+					} else if nextStateName > startStateName && !visited[nextStateName] {
+						dfs(nextStateName)
+					}
+				}
+				visited[stateName] = false
+				path = path[:len(path)-1]
+			}
+			dfs(startStateName)
+		}
+	}
+
+	// DeadEndStates and UnreachableStates
+	for _, stateName := range tma.States {
+		if !isFinalState(stateName) {
+			reachesFinalState := false
+			for _, finalStateName := range tma.FinalStates {
+				if canReach(transitionsMap, stateName, finalStateName) {
+					reachesFinalState = true
+					break
+				}
+			}
+			if !reachesFinalState {
+				tma.DeadEndStates = append(tma.DeadEndStates, stateName)
+			}
+		}
+		if !canReach(transitionsMap, initialStateName, stateName) {
+			tma.UnreachableStates = append(tma.UnreachableStates, stateName)
+		}
+	}
+
+	tma.StronglyConnectedComponents = stronglyConnectedComponents(transitionsMap, tma.States)
+
+	return tma
+}
+
+// Validate returns an error if there are DeadEndStates or UnreachableStates, or if InitialStateName is not in the transitionsMap,
+// or if the patterns of the transitionsMap could not be expanded
+func (tma *TransitionsMapAnalysis) Validate() error {
+	var problems []string
+	if tma.expandErr != nil {
+		problems = append(problems, tma.expandErr.Error())
+	}
+	if !containsString(tma.States, tma.InitialStateName) {
+		problems = append(problems, fmt.Sprintf("initial state '%s' is not in the transitionsMap", tma.InitialStateName))
+	}
+	if len(tma.DeadEndStates) > 0 {
+		problems = append(problems, fmt.Sprintf("dead-end states (cannot reach any final state): %v", tma.DeadEndStates))
+	}
+	if len(tma.UnreachableStates) > 0 {
+		problems = append(problems, fmt.Sprintf("unreachable states (from initial state '%s'): %v", tma.InitialStateName, tma.UnreachableStates))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid transitionsMap: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Report returns a text report of the analysis
+func (tma *TransitionsMapAnalysis) Report() string {
+	str := "initial state: " + tma.InitialStateName + "\n"
+	str += "states: " + strings.Join(tma.States, ", ") + "\n"
+	str += "final states: " + strings.Join(tma.FinalStates, ", ") + "\n"
+	for _, finalStateName := range tma.FinalStates {
+		paths := tma.PathsToFinalStates[finalStateName]
+		str += "paths to " + finalStateName + ": " + strconv.Itoa(len(paths)) + "\n"
+		for _, path := range paths {
+			str += "\t" + strings.Join(path, " -> ") + "\n"
+		}
+	}
+	if tma.PathsTruncated {
+		str += "paths truncated at " + strconv.Itoa(TransitionsMapAnalysisMaxPaths) + " paths or " + strconv.Itoa(TransitionsMapAnalysisMaxSteps) + " steps\n"
+	}
+	str += "cycles: " + strconv.Itoa(len(tma.Cycles)) + "\n"
+	for _, cycle := range tma.Cycles {
+		str += "\t" + strings.Join(cycle, " -> ") + " -> " + cycle[0] + "\n"
+	}
+	if tma.CyclesTruncated {
+		str += "cycles truncated at " + strconv.Itoa(TransitionsMapAnalysisMaxPaths) + " cycles or " + strconv.Itoa(TransitionsMapAnalysisMaxSteps) + " steps\n"
+	}
+	str += "dead-end states: " + strings.Join(tma.DeadEndStates, ", ") + "\n"
+	str += "unreachable states: " + strings.Join(tma.UnreachableStates, ", ") + "\n"
+	str += "strongly connected components: " + strconv.Itoa(len(tma.StronglyConnectedComponents)) + "\n"
+	for _, scc := range tma.StronglyConnectedComponents {
+		str += "\t{" + strings.Join(scc, ", ") + "}\n"
+	}
+	return str
+}
+
+// Returns true if stateName has no transitions in transitionsMap
+func isFinalStateInTransitionsMap(transitionsMap map[string][]string, stateName string) bool {
+	return len(transitionsMap[stateName]) == 0
+}
+
+// Returns true if there is a path (of zero or more transitions) from sourceStateName to destinationStateName
+func canReach(transitionsMap map[string][]string, sourceStateName string, destinationStateName string) bool {
+	visited := map[string]bool{sourceStateName: true}
+	pending := []string{sourceStateName}
+	for len(pending) > 0 {
+		stateName := pending[0]
+		pending = pending[1:]
+		if stateName == destinationStateName {
+			return true
+		}
+		for _, nextStateName := range transitionsMap[stateName] {
+			if !visited[nextStateName] {
+				visited[nextStateName] = true
+				pending = append(pending, nextStateName)
+			}
+		}
+	}
+	return false
+}
+
+// Tarjan's algorithm. Each component and the list of components are sorted
+func stronglyConnectedComponents(transitionsMap map[string][]string, stateNames []string) [][]string {
+	index := 0
+	indexes := make(map[string]int)
+	lowlinks := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var sccs [][]string
+
+	var strongconnect func(stateName string)
+	strongconnect = func(stateName string) {
+		indexes[stateName] = index
+		lowlinks[stateName] = index
+		index++
+		stack = append(stack, stateName)
+		onStack[stateName] = true
+
+		for _, nextStateName := range transitionsMap[stateName] {
+			if _, ok := indexes[nextStateName]; !ok {
+				strongconnect(nextStateName)
+				if lowlinks[nextStateName] < lowlinks[stateName] {
+					lowlinks[stateName] = lowlinks[nextStateName]
+				}
+			} else if onStack[nextStateName] && indexes[nextStateName] < lowlinks[stateName] {
+				lowlinks[stateName] = indexes[nextStateName]
+			}
+		}
+
+		if lowlinks[stateName] == indexes[stateName] {
+			var scc []string
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				scc = append(scc, top)
+				if top == stateName {
+					break
+				}
+			}
+			sort.Strings(scc)
+			sccs = append(sccs, scc)
+		}
+	}
+	for _, stateName := range stateNames {
+		if _, ok := indexes[stateName]; !ok {
+			strongconnect(stateName)
+		}
+	}
+	sort.Slice(sccs, func(i, j int) bool { return sccs[i][0] < sccs[j][0] })
+	return sccs
+}
+
+func containsString(slice []string, str string) bool {
+	for _, s := range slice {
+		if s == str {
+			return true
+		}
+	}
+	return false
+}
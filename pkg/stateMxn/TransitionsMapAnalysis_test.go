@@ -0,0 +1,139 @@
+package stateMxn
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var analysisTransitionsMap = map[string][]string{
+	"Init":     {"Running", "Orphan2"},
+	"Running":  {"Retry", "FinishedOk", "FinishedNok"},
+	"Retry":    {"Running"},
+	"Orphan1":  {"Init"},
+	"Orphan2":  {"DeadLoop"},
+	"DeadLoop": {"Orphan2"},
+}
+
+func TestAnalyzeTransitionsMap(t *testing.T) {
+	tma := AnalyzeTransitionsMap(analysisTransitionsMap, "Init")
+
+	if want := []string{"FinishedNok", "FinishedOk"}; !reflect.DeepEqual(tma.FinalStates, want) {
+		t.Errorf("FinalStates = %v - want %v", tma.FinalStates, want)
+	}
+	if got, want := tma.PathsToFinalStates["FinishedOk"], [][]string{{"Init", "Running", "FinishedOk"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("PathsToFinalStates[FinishedOk] = %v - want %v", got, want)
+	}
+	if want := [][]string{{"DeadLoop", "Orphan2"}, {"Retry", "Running"}}; !reflect.DeepEqual(tma.Cycles, want) {
+		t.Errorf("Cycles = %v - want %v", tma.Cycles, want)
+	}
+	if want := []string{"DeadLoop", "Orphan2"}; !reflect.DeepEqual(tma.DeadEndStates, want) {
+		t.Errorf("DeadEndStates = %v - want %v", tma.DeadEndStates, want)
+	}
+	if want := []string{"Orphan1"}; !reflect.DeepEqual(tma.UnreachableStates, want) {
+		t.Errorf("UnreachableStates = %v - want %v", tma.UnreachableStates, want)
+	}
+	if want := [][]string{{"DeadLoop", "Orphan2"}, {"FinishedNok"}, {"FinishedOk"}, {"Init"}, {"Orphan1"}, {"Retry", "Running"}}; !reflect.DeepEqual(tma.StronglyConnectedComponents, want) {
+		t.Errorf("StronglyConnectedComponents = %v - want %v", tma.StronglyConnectedComponents, want)
+	}
+	if tma.PathsTruncated || tma.CyclesTruncated {
+		t.Errorf("PathsTruncated = %v, CyclesTruncated = %v - want both false", tma.PathsTruncated, tma.CyclesTruncated)
+	}
+
+	err := tma.Validate()
+	if err == nil || !strings.Contains(err.Error(), "dead-end states") || !strings.Contains(err.Error(), "unreachable states") {
+		t.Errorf("Validate() = %v - want dead-end and unreachable states", err)
+	}
+	report := tma.Report()
+	for _, want := range []string{
+		"paths to FinishedOk: 1",
+		"\tInit -> Running -> FinishedOk\n",
+		"\tRetry -> Running -> Retry\n",
+		"dead-end states: DeadLoop, Orphan2",
+		"unreachable states: Orphan1",
+	} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Report() does not contain %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestAnalyzeValidTransitionsMap(t *testing.T) {
+	tma := AnalyzeTransitionsMap(map[string][]string{
+		"Init":    {"Running", "FinishedNok"},
+		"Running": {"FinishedOk", "FinishedNok"},
+	}, "Init")
+	if err := tma.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+	if got := len(tma.PathsToFinalStates["FinishedNok"]); got != 2 {
+		t.Errorf("%d paths to FinishedNok - want 2", got)
+	}
+
+	if err := AnalyzeTransitionsMap(map[string][]string{"Init": {"Done"}}, "Bogus").Validate(); err == nil || !strings.Contains(err.Error(), "initial state 'Bogus'") {
+		t.Errorf("Validate() with an unknown initial state = %v", err)
+	}
+	if err := AnalyzeTransitionsMap(map[string][]string{"Init": {"/Nothing.*/"}}, "Init").Validate(); err == nil {
+		t.Error("Validate() with a pattern matching no state did not fail")
+	}
+}
+
+func TestCanReach(t *testing.T) {
+	smg, err := NewStateMxnGeneric("smx", analysisTransitionsMap, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		source, destination string
+		want                bool
+	}{
+		{"Init", "FinishedOk", true},
+		{"Retry", "FinishedNok", true},
+		{"Init", "Init", true},
+		{"Orphan2", "FinishedOk", false},
+		{"Running", "Orphan1", false},
+		{"Bogus", "Init", false},
+		{"Init", "Bogus", false},
+	} {
+		if got := smg.CanReach(tc.source, tc.destination); got != tc.want {
+			t.Errorf("CanReach(%s, %s) = %v - want %v", tc.source, tc.destination, got, tc.want)
+		}
+	}
+
+	// from the current state
+	if smg.CanReach("", "FinishedOk") {
+		t.Error("CanReach() without a current state = true")
+	}
+	_ = smg.Change("Init")
+	_ = smg.Change("Orphan2")
+	if smg.CanReach("", "FinishedOk") || !smg.CanReach("", "DeadLoop") {
+		t.Errorf("CanReach() from Orphan2 = %v, %v - want false, true", smg.CanReach("", "FinishedOk"), smg.CanReach("", "DeadLoop"))
+	}
+}
+
+func TestAnalysisIsBounded(t *testing.T) {
+	// a complete graph of n states has more than (n-2)! simple paths between 2 states
+	const n = 14
+	tMap := make(map[string][]string)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i != j {
+				tMap[fmt.Sprintf("S%02d", i)] = append(tMap[fmt.Sprintf("S%02d", i)], fmt.Sprintf("S%02d", j))
+			}
+		}
+		tMap[fmt.Sprintf("S%02d", i)] = append(tMap[fmt.Sprintf("S%02d", i)], "Done")
+	}
+
+	var tma *TransitionsMapAnalysis
+	runWithTimeout(t, 30*time.Second, func() {
+		tma = AnalyzeTransitionsMap(tMap, "S00")
+	})
+	if !tma.PathsTruncated || !tma.CyclesTruncated {
+		t.Errorf("PathsTruncated = %v, CyclesTruncated = %v - want both true", tma.PathsTruncated, tma.CyclesTruncated)
+	}
+	if len(tma.PathsToFinalStates["Done"]) > TransitionsMapAnalysisMaxPaths || len(tma.Cycles) > TransitionsMapAnalysisMaxPaths {
+		t.Errorf("%d paths and %d cycles - more than %d", len(tma.PathsToFinalStates["Done"]), len(tma.Cycles), TransitionsMapAnalysisMaxPaths)
+	}
+}
@@ -0,0 +1,191 @@
+package stateMxn
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+/*
+Patterns in the transitionsMap: besides state names, the sources and destinations of a transitionsMap can be:
+  - "*" as a source: from any non-final state, except into itself (a self-loop must be listed explicitly, with the state as source)
+  - a regexp RE2 enclosed in slashes "/<regexp>/", with the same syntax and matching as smg.Is(), so unanchored: ex "/Running/"
+    matches "RunningAlpha" and "PreRunning", while "/^Running/" only matches "RunningAlpha".
+    Any other source or destination is a plain state name, even if it contains regexp metacharacters (ex: "Step.1" or "Wait(approval)")
+
+The states of the smachine are the (plain) state names of the transitionsMap, and the patterns are expanded over them
+(see expandTransitionsMap()), into the transitionsMap used by the smachine (smg.GetTransitionsMap()), its validations and
+analysis. The plantuml of the transitionsMap shows the patterns unexpanded, with "*" drawn as an "any" pseudo-state.
+
+Example: instead of listing "FinishedNok" in every state, and "Paused" in every Running state
+
+	transitionsMap := map[string][]string{
+		"Init":         {"RunningAlpha"},
+		"RunningAlpha": {"RunningBeta"},
+		"RunningBeta":  {"FinishedOk"},
+		"*":            {"FinishedNok"},
+		"/^Running/":   {"Paused"},
+		"Paused":       {"/^Running/"},
+	}
+
+The destinations expanded from patterns are appended after the destinations listed explicitly (so for a StateMxnSimpleflow,
+the above "*" adds FinishedNok as the last ("Nok") transition of every non-final state)
+*/
+
+const transitionsMapAnySource = "*"
+
+// delimiter of the regexp patterns: "/<regexp>/"
+const transitionsMapRegexpDelimiter = "/"
+
+// Returns true if s is "*" or a regexp (ie, "/<regexp>/"), instead of a state name
+func isTransitionsMapPattern(s string) bool {
+	return s == transitionsMapAnySource || isTransitionsMapRegexp(s)
+}
+
+// Returns true if s is a regexp pattern "/<regexp>/"
+func isTransitionsMapRegexp(s string) bool {
+	return len(s) > 2 && strings.HasPrefix(s, transitionsMapRegexpDelimiter) && strings.HasSuffix(s, transitionsMapRegexpDelimiter)
+}
+
+// Returns true if tMap contains any pattern
+func hasTransitionsMapPatterns(tMap map[string][]string) bool {
+	for source, destinations := range tMap {
+		if isTransitionsMapPattern(source) {
+			return true
+		}
+		for _, destination := range destinations {
+			if isTransitionsMapPattern(destination) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expandTransitionsMap returns a new transitionsMap, where the patterns of tMap are replaced by the state names they match.
+// Its deterministic, and in the result:
+//   - first come the transitions of plain sources, then the transitions of regexp sources (in sorted order), and lastly the
+//     transitions of "*" (applied to the states which are non-final after the previous ones)
+//   - each source has no repeated destinations
+//   - "*" adds no self-loops
+//
+// Returns an error if a regexp is invalid, or a pattern does not match any state
+func expandTransitionsMap(tMap map[string][]string) (map[string][]string, error) {
+	// stateNames - the plain state names
+	var stateNames []string
+	{
+		set := make(map[string]bool)
+		for source, destinations := range tMap {
+			if !isTransitionsMapPattern(source) {
+				set[source] = true
+			}
+			for _, destination := range destinations {
+				if !isTransitionsMapPattern(destination) {
+					set[destination] = true
+				}
+			}
+		}
+		for stateName := range set {
+			stateNames = append(stateNames, stateName)
+		}
+		sort.Strings(stateNames)
+	}
+
+	// Returns the stateNames matched by pattern (or pattern itself, if its a state name)
+	matchStates := func(pattern string) ([]string, error) {
+		if !isTransitionsMapPattern(pattern) {
+			return []string{pattern}, nil
+		}
+		if pattern == transitionsMapAnySource {
+			return nil, fmt.Errorf("'%s' can only be used as a source", transitionsMapAnySource)
+		}
+		patternRegexp := strings.TrimSuffix(strings.TrimPrefix(pattern, transitionsMapRegexpDelimiter), transitionsMapRegexpDelimiter)
+		re, err := regexp.Compile(patternRegexp)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp '%s': %w", pattern, err)
+		}
+		var matched []string
+		for _, stateName := range stateNames {
+			if re.MatchString(stateName) {
+				matched = append(matched, stateName)
+			}
+		}
+		if len(matched) == 0 {
+			return nil, fmt.Errorf("regexp '%s' does not match any state", pattern)
+		}
+		return matched, nil
+	}
+
+	expanded := make(map[string][]string)
+	// skipSelfLoop - to not add source itself as a destination
+	addTransitions := func(source string, destinations []string, skipSelfLoop bool) error {
+		if _, ok := expanded[source]; !ok {
+			expanded[source] = []string{}
+		}
+		for _, destinationPattern := range destinations {
+			matched, err := matchStates(destinationPattern)
+			if err != nil {
+				return err
+			}
+			for _, destination := range matched {
+				if skipSelfLoop && destination == source {
+					continue
+				}
+				if !containsString(expanded[source], destination) {
+					expanded[source] = append(expanded[source], destination)
+				}
+			}
+		}
+		return nil
+	}
+
+	// plain sources, and then regexp sources
+	var regexpSources []string
+	for _, source := range sortedKeys(tMap) {
+		if source == transitionsMapAnySource {
+			continue
+		}
+		if isTransitionsMapPattern(source) {
+			regexpSources = append(regexpSources, source)
+			continue
+		}
+		if err := addTransitions(source, tMap[source], false); err != nil {
+			return nil, err
+		}
+	}
+	for _, sourcePattern := range regexpSources {
+		matched, err := matchStates(sourcePattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, source := range matched {
+			if err := addTransitions(source, tMap[sourcePattern], false); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// "*" sources
+	if anyDestinations, ok := tMap[transitionsMapAnySource]; ok {
+		var nonFinalStates []string
+		for _, stateName := range stateNames {
+			if !isFinalStateInTransitionsMap(expanded, stateName) {
+				nonFinalStates = append(nonFinalStates, stateName)
+			}
+		}
+		for _, source := range nonFinalStates {
+			if err := addTransitions(source, anyDestinations, true); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Remove sources without transitions (like in a plain transitionsMap, final states are only destinations)
+	for source, destinations := range expanded {
+		if _, ok := tMap[source]; !ok && len(destinations) == 0 {
+			delete(expanded, source)
+		}
+	}
+	return expanded, nil
+}
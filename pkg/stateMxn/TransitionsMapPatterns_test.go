@@ -0,0 +1,124 @@
+package stateMxn
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStateNamesWithRegexpMetacharactersAreLiteral(t *testing.T) {
+	smg, err := NewStateMxnGeneric("smx", map[string][]string{
+		"Step.1":         {"Wait(approval)"},
+		"Wait(approval)": {"Step.2", "Failed"},
+		"StepX1":         {"Failed"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"Step.1":         {"Wait(approval)"},
+		"Wait(approval)": {"Step.2", "Failed"},
+		"StepX1":         {"Failed"},
+	}
+	if got := smg.GetTransitionsMap(); !reflect.DeepEqual(got, want) {
+		t.Errorf("transitionsMap = %v - want %v", got, want)
+	}
+	for _, stateName := range []string{"Step.1", "Wait(approval)", "Step.2"} {
+		if err := smg.Change(stateName); err != nil {
+			t.Fatalf("Change(%s): %s", stateName, err)
+		}
+	}
+}
+
+func TestRegexpPatterns(t *testing.T) {
+	expanded, err := expandTransitionsMap(map[string][]string{
+		"Init":         {"RunningAlpha"},
+		"RunningAlpha": {"RunningBeta"},
+		"RunningBeta":  {"FinishedOk"},
+		"/Running.+/":  {"Paused"},
+		"Paused":       {"/Running.+/"},
+		"*":            {"FinishedNok"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"Init":         {"RunningAlpha", "FinishedNok"},
+		"RunningAlpha": {"RunningBeta", "Paused", "FinishedNok"},
+		"RunningBeta":  {"FinishedOk", "Paused", "FinishedNok"},
+		"Paused":       {"RunningAlpha", "RunningBeta", "FinishedNok"},
+	}
+	if !reflect.DeepEqual(expanded, want) {
+		t.Errorf("expanded = %v - want %v", expanded, want)
+	}
+
+	for _, tMap := range []map[string][]string{
+		{"Init": {"/Nothing.*/"}},
+		{"Init": {"/Running(/"}},
+		{"Init": {"*"}},
+	} {
+		if _, err := expandTransitionsMap(tMap); err == nil {
+			t.Errorf("expandTransitionsMap(%v) did not fail", tMap)
+		}
+	}
+}
+
+func TestRegexpPatternsAreUnanchoredLikeIs(t *testing.T) {
+	tMap := map[string][]string{
+		"Init":         {"PreRunning"},
+		"PreRunning":   {"RunningAlpha"},
+		"RunningAlpha": {"Done"},
+		"/Running/":    {"Paused"},
+		"Paused":       {"/^Running/"},
+	}
+	expanded, err := expandTransitionsMap(tMap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"Init":         {"PreRunning"},
+		"PreRunning":   {"RunningAlpha", "Paused"},
+		"RunningAlpha": {"Done", "Paused"},
+		"Paused":       {"RunningAlpha"},
+	}
+	if !reflect.DeepEqual(expanded, want) {
+		t.Errorf("expanded = %v - want %v", expanded, want)
+	}
+
+	// the patterns match the same states as smg.Is()
+	smg, err := NewStateMxnGeneric("smx", tMap, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stateName := range []string{"Init", "PreRunning"} {
+		if err := smg.Change(stateName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if is, _ := smg.Is("Running"); !is {
+		t.Error(`smg.Is("Running") does not match PreRunning, while the pattern "/Running/" does`)
+	}
+}
+
+func TestAnySourceAddsNoSelfLoops(t *testing.T) {
+	expanded, err := expandTransitionsMap(map[string][]string{
+		"Init":      {"Running"},
+		"Running":   {"Done"},
+		"Cancelled": {"Cancelled", "Init"},
+		"Retrying":  {"Running"},
+		"*":         {"Cancelled", "Retrying"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"Init":    {"Running", "Cancelled", "Retrying"},
+		"Running": {"Done", "Cancelled", "Retrying"},
+		// the self-loop listed explicitly is kept
+		"Cancelled": {"Cancelled", "Init", "Retrying"},
+		// "*" does not add Retrying -> Retrying
+		"Retrying": {"Running", "Cancelled"},
+	}
+	if !reflect.DeepEqual(expanded, want) {
+		t.Errorf("expanded = %v - want %v", expanded, want)
+	}
+}
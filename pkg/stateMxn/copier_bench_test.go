@@ -0,0 +1,137 @@
+package stateMxn
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+// Benchmarks the Copier strategies (deep, shallow, Clone(), copy-on-write) on realistic payloads, copying the payload alone
+func BenchmarkCopiers(b *testing.B) {
+	for _, nRecords := range []int{10, 1000} {
+		for _, strategy := range benchCopierStrategies() {
+			b.Run(fmt.Sprintf("%s/records=%d", strategy.name, nRecords), func(b *testing.B) {
+				payload := strategy.newPayload(nRecords)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					strategy.copier.Copy(payload)
+				}
+			})
+		}
+	}
+}
+
+// Like BenchmarkCopiers, but running a smachine that passes the payload through its states (outputs->inputs)
+func BenchmarkCopiersInSmachine(b *testing.B) {
+	for _, nRecords := range []int{10, 1000} {
+		for _, strategy := range benchCopierStrategies() {
+			b.Run(fmt.Sprintf("%s/records=%d", strategy.name, nRecords), func(b *testing.B) {
+				payload := strategy.newPayload(nRecords)
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					if err := benchRunSmachine(strategy.copier, payload); err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+type benchRecord struct {
+	Id         int
+	Name       string
+	Tags       []string
+	Attributes map[string]string
+}
+
+// benchRecords implements Cloner, to benchmark the Clone() strategy
+type benchRecords []benchRecord
+
+func (brs benchRecords) Clone() interface{} {
+	brsClone := make(benchRecords, len(brs))
+	for i, br := range brs {
+		brClone := br
+		brClone.Tags = append([]string{}, br.Tags...)
+		brClone.Attributes = make(map[string]string, len(br.Attributes))
+		for k, v := range br.Attributes {
+			brClone.Attributes[k] = v
+		}
+		brsClone[i] = brClone
+	}
+	return brsClone
+}
+
+type benchCopierStrategy struct {
+	name       string
+	copier     Copier
+	newPayload func(nRecords int) map[string]interface{}
+}
+
+func benchCopierStrategies() []benchCopierStrategy {
+	return []benchCopierStrategy{
+		{"deep", NewDeepCopier(), func(n int) map[string]interface{} { return newBenchPayload([]benchRecord(newBenchRecords(n))) }},
+		{"shallow", NewShallowCopier(), func(n int) map[string]interface{} { return newBenchPayload([]benchRecord(newBenchRecords(n))) }},
+		{"cloner", NewClonerCopier(), func(n int) map[string]interface{} { return newBenchPayload(newBenchRecords(n)) }},
+		{"cow", NewCopyOnWriteCopier(), func(n int) map[string]interface{} {
+			return newBenchPayload(NewCowValue(newBenchRecords(n)))
+		}},
+	}
+}
+
+func newBenchRecords(nRecords int) benchRecords {
+	records := make(benchRecords, nRecords)
+	for i := range records {
+		records[i] = benchRecord{
+			Id:         i,
+			Name:       "record-" + strconv.Itoa(i),
+			Tags:       []string{"a", "b", "c"},
+			Attributes: map[string]string{"owner": "someone", "region": "eu-west-1", "tier": strconv.Itoa(i % 3)},
+		}
+	}
+	return records
+}
+
+func newBenchPayload(records interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"requestId": "0123456789abcdef",
+		"retries":   3,
+		"config":    map[string]interface{}{"timeoutSeconds": 30, "endpoints": []string{"http://a", "http://b"}},
+		"records":   records,
+	}
+}
+
+// Runs a smachine Init -> Process -> Finished, where each state passes its inputs into its outputs
+func benchRunSmachine(copier Copier, payload map[string]interface{}) error {
+	passThrough := func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		for k, v := range inputs {
+			outputs[k] = v
+		}
+		return nil
+	}
+	stateInit := NewState("Init")
+	stateInit.AddHandlerExec(func(inputs StateInputs, outputs StateOutputs, stateData StateData, smData StateMxnData) error {
+		for k, v := range payload {
+			outputs[k] = v
+		}
+		return nil
+	})
+	stateProcess := NewState("Process")
+	stateProcess.AddHandlerExec(passThrough)
+	stateFinished := NewState("Finished")
+	stateFinished.AddHandlerExec(passThrough)
+	precreatedStates := map[string]StateIfc{"Init": stateInit, "Process": stateProcess, "Finished": stateFinished}
+	smg, err := NewStateMxnGeneric("benchCopiers", map[string][]string{"Init": {"Process"}, "Process": {"Finished"}}, precreatedStates)
+	if err != nil {
+		return err
+	}
+	smg.SetCopier(copier)
+	for _, name := range []string{"Init", "Process", "Finished"} {
+		if err := smg.Change(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,6 +2,9 @@ package stateMxn
 
 import (
 	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -32,3 +35,42 @@ func splitAndIdentLines(str string, ncharts int, indent string) string {
 func replace2alphanum(s string) string {
 	return regexp.MustCompile(`[^a-zA-Z0-9]+`).ReplaceAllString(s, "_")
 }
+
+// Returns the keys of transitionsMap, sorted
+func sortedKeys(transitionsMap map[string][]string) []string {
+	keys := make([]string, 0, len(transitionsMap))
+	for k := range transitionsMap {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Returns all the state names present in transitionsMap (either as sources or destinations), sorted and without duplicates
+func allStatenames(transitionsMap map[string][]string) []string {
+	set := make(map[string]bool)
+	for source, destinations := range transitionsMap {
+		set[source] = true
+		for _, destination := range destinations {
+			set[destination] = true
+		}
+	}
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Returns the id of the calling goroutine, parsed from the header of its stack trace: "goroutine <id> [running]:"
+func goroutineId() int64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	fields := strings.Fields(string(buf[:n]))
+	if len(fields) < 2 {
+		return 0
+	}
+	id, _ := strconv.ParseInt(fields[1], 10, 64)
+	return id
+}
@@ -3,6 +3,7 @@ package stateMxn
 import (
 	"fmt"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/trislu/plantuml"
@@ -111,8 +112,43 @@ skinparam sequenceReferenceAlign left
 						},
 						"timeEnd":   func(k string, v interface{}, mapName string) string { return "" },
 						"timeStart": func(k string, v interface{}, mapName string) string { return "" },
+						// all the errors are shown (from data["errors"])
 						"error": func(k string, v interface{}, mapName string) string {
-							return mapName + "[" + k + "]: " + fmt.Sprintf("%s", v.(error).Error()) + `\n`
+							str := ""
+							for _, err := range getErrorsFromData(d) {
+								str += mapName + "[" + k + "]: " + err.Error() + `\n`
+							}
+							return str
+						},
+						"errors": func(k string, v interface{}, mapName string) string { return "" },
+						"firedTimedTransition": func(k string, v interface{}, mapName string) string {
+							return mapName + "[" + k + "]: " + v.(TimedTransitionFiring).String() + `\n`
+						},
+						"transitionActionErrors": func(k string, v interface{}, mapName string) string {
+							str := ""
+							for _, taErr := range v.([]error) {
+								str += "TRANSITION-ACTION-ERROR " + taErr.Error() + `\n`
+							}
+							return str
+						},
+						"handlers": func(k string, v interface{}, mapName string) string {
+							str := ""
+							for _, hRecord := range v.([]HandlerRecord) {
+								str += "handler " + hRecord.String() + `\n`
+							}
+							return str
+						},
+						"timePhases": func(k string, v interface{}, mapName string) string {
+							if hRecords, _ := d["handlers"].([]HandlerRecord); len(hRecords) == 0 {
+								return ""
+							}
+							return "phases: " + timePhasesString(v.(map[HandlerPhase]time.Duration)) + `\n`
+						},
+						"smxDataDiff": func(k string, v interface{}, mapName string) string {
+							if v.(StateMxnDataDiff).IsEmpty() {
+								return ""
+							}
+							return "smx.data changes: " + v.(StateMxnDataDiff).String() + `\n`
 						},
 					}),
 					`\n`,
@@ -124,9 +160,15 @@ skinparam sequenceReferenceAlign left
 					d,
 					"smx.data",
 					specialKeysType(map[string]func(k string, v interface{}, mapName string) string{
+						// all the errors are shown (from data["errors"])
 						"error": func(k string, v interface{}, mapName string) string {
-							return mapName + "[" + k + "]: " + fmt.Sprintf("%s", v.(error).Error()) + `\n`
+							str := ""
+							for _, err := range getErrorsFromData(d) {
+								str += mapName + "[" + k + "]: " + err.Error() + "\n"
+							}
+							return str
 						},
+						"errors": func(k string, v interface{}, mapName string) string { return "" },
 					}),
 					"\n",
 				)
@@ -159,8 +201,8 @@ skinparam sequenceReferenceAlign left
 				var prevStateErr string
 				{
 					prevStateErr = ""
-					if smx.GetHistoryOfStates()[i-1].GetError() != nil {
-						prevStateErr = `\nERROR ` + smx.GetHistoryOfStates()[i-1].GetError().Error()
+					for _, serr := range smx.GetHistoryOfStates()[i-1].GetErrors() {
+						prevStateErr += `\nERROR ` + serr.Error()
 					}
 				}
 				var nextStateName string
@@ -173,7 +215,12 @@ skinparam sequenceReferenceAlign left
 					}
 				}
 				// prevStateName --> nextStateName : prevStateOutputsStr + prevStateErr \n
-				{
+				// (the last state only goes into [*] if its a final state, otherwise its outputs are shown in the state itself)
+				if nextStateName == "[*]" && !isFinalStateOfSmx(smx, smx.GetHistoryOfStates()[i-1].GetName()) {
+					if len(prevStateOutputsStr+prevStateErr) > 0 {
+						body += prevStateName + " : " + prevStateOutputsStr + prevStateErr + "\n"
+					}
+				} else {
 					body += prevStateName + " --> " + nextStateName
 					if len(prevStateOutputsStr+prevStateErr) > 0 {
 						body += " : " + prevStateOutputsStr + prevStateErr
@@ -205,7 +252,32 @@ skinparam sequenceReferenceAlign left
 	return text, diagramUrl
 }
 
-func plantUmlGen4TransitionsMap(transitionsMap map[string][]string) (text string, diagramUrl string) {
+type plantUmlGen4TransitionsMapOpts struct {
+	// arrowFunc returns the plantuml arrow to draw each transition fromState -> toState. When nil, "-[dotted]->" is used
+	arrowFunc func(fromState string, toState string) string
+
+	// states - when given, the inputs/outputs schemas of each state are drawn in the state, and each transition is labeled
+	// with the keys that fromState promises in its outputs and toState requires in its inputs (ie, the data flowing in that transition)
+	states map[string]StateIfc
+
+	// initialStateNames and finalStateNames - when given, drawn with transitions from/to [*]
+	initialStateNames []string
+	finalStateNames   []string
+}
+
+// transitionsMap can contain patterns, which are drawn unexpanded (see TransitionsMapPatterns.go)
+//
+// opts can be nil
+func plantUmlGen4TransitionsMap(transitionsMap map[string][]string, opts *plantUmlGen4TransitionsMapOpts) (text string, diagramUrl string) {
+	if opts == nil {
+		opts = &plantUmlGen4TransitionsMapOpts{}
+	}
+	arrowFunc := opts.arrowFunc
+	if arrowFunc == nil {
+		arrowFunc = func(fromState string, toState string) string { return "-[dotted]->" }
+	}
+	states := opts.states
+
 	var header, footer string
 	{
 		header = `
@@ -220,9 +292,86 @@ skinparam sequenceReferenceAlign left
 	var body string
 	{
 		body = ""
-		for fromState, toStates := range transitionsMap {
-			for _, toState := range toStates {
-				body += fromState + " -[dotted]-> " + toState + "\n"
+		// patterns (see TransitionsMapPatterns.go) are drawn as pseudo-states: "*" as "any", and each regexp with its own alias
+		aliases := make(map[string]string)
+		{
+			for _, stateName := range allStatenames(transitionsMap) {
+				if !isTransitionsMapPattern(stateName) {
+					continue
+				}
+				if stateName == transitionsMapAnySource {
+					aliases[stateName] = "any"
+					body += "state \"any\" as any #line.dashed\n"
+					continue
+				}
+				aliases[stateName] = "pattern" + strconv.Itoa(len(aliases))
+				body += "state \"" + stateName + "\" as " + aliases[stateName] + " #line.dashed\n"
+			}
+		}
+		alias := func(stateName string) string {
+			if a, ok := aliases[stateName]; ok {
+				return a
+			}
+			return stateName
+		}
+		for _, initialStateName := range opts.initialStateNames {
+			body += "[*] --> " + initialStateName + "\n"
+		}
+		for _, fromState := range sortedKeys(transitionsMap) {
+			for _, toState := range transitionsMap[fromState] {
+				body += alias(fromState) + " " + arrowFunc(fromState, toState) + " " + alias(toState)
+				if states[fromState] != nil && states[toState] != nil {
+					var flowingKeys []string
+					for _, key := range states[toState].GetInputsSchema().sortedKeys() {
+						if _, ok := states[fromState].GetOutputsSchema()[key]; ok {
+							flowingKeys = append(flowingKeys, key)
+						}
+					}
+					if len(flowingKeys) > 0 {
+						body += " : " + strings.Join(flowingKeys, `\n`)
+					}
+				}
+				body += "\n"
+			}
+		}
+		for _, finalStateName := range opts.finalStateNames {
+			body += finalStateName + " --> [*]\n"
+		}
+		// schemas and handler-names of the states
+		for _, stateName := range allStatenames(transitionsMap) {
+			state, ok := states[stateName]
+			if !ok || state == nil {
+				continue
+			}
+			// handler-names are drawn only for states with named handlers (see State.AddHandlerExecNamed())
+			{
+				hasNamedHandlers := false
+				var phasesStrs []string
+				for _, phase := range []HandlerPhase{HandlerPhaseBegin, HandlerPhaseExec, HandlerPhaseEnd} {
+					names := state.GetHandlerNames(phase)
+					if len(names) == 0 {
+						continue
+					}
+					var namesStrs []string
+					for _, name := range names {
+						if name == "" {
+							name = "(unnamed)"
+						} else {
+							hasNamedHandlers = true
+						}
+						namesStrs = append(namesStrs, name)
+					}
+					phasesStrs = append(phasesStrs, string(phase)+": "+strings.Join(namesStrs, ", "))
+				}
+				if hasNamedHandlers {
+					body += stateName + " : handlers: " + strings.Join(phasesStrs, `\n`) + "\n"
+				}
+			}
+			if len(state.GetInputsSchema()) > 0 {
+				body += stateName + " : inputs: " + state.GetInputsSchema().String() + "\n"
+			}
+			if len(state.GetOutputsSchema()) > 0 {
+				body += stateName + " : outputs: " + state.GetOutputsSchema().String() + "\n"
 			}
 		}
 	}
@@ -233,3 +382,11 @@ skinparam sequenceReferenceAlign left
 
 	return text, diagramUrl
 }
+
+// Returns true if stateName is a final state of smx (for a StateMxnIfc implemented outside of this package: a state without transitions)
+func isFinalStateOfSmx(smx StateMxnIfc, stateName string) bool {
+	if smg, ok := asStateMxnGeneric(smx); ok {
+		return smg.isFinalState(stateName)
+	}
+	return len(smx.GetTransitionsMap()[stateName]) == 0
+}
@@ -0,0 +1,298 @@
+package stateMxntest
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/zipizapclouds/stateMxn/pkg/stateMxn"
+)
+
+// Invariant is a condition checked after every state-change of a simulation. Check returns an error when the condition is broken
+type Invariant struct {
+	Name  string
+	Check func(smg *stateMxn.StateMxnGeneric) error
+}
+
+// InvariantErrorOnlyIn returns an Invariant that is broken when smx.data["error"] is set while the current state does not match
+// (unanchored, like smg.Is()) stateRegexp. Ex: InvariantErrorOnlyIn("^Finished")
+func InvariantErrorOnlyIn(stateRegexp string) Invariant {
+	return Invariant{
+		Name: "error only in " + stateRegexp,
+		Check: func(smg *stateMxn.StateMxnGeneric) error {
+			if smg.GetError() == nil {
+				return nil
+			}
+			ok, err := smg.Is(stateRegexp)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("smx.data[\"error\"] is set in state '%s': %s", smg.GetCurrentState().GetName(), smg.GetError())
+			}
+			return nil
+		},
+	}
+}
+
+/*
+Simulator drives smachines with random (but valid) state-changes, checking invariants after every step.
+
+Each simulation creates a fresh smachine with newSmx() - which decides if the states have their real handlers or stubs (see StubHandler) -
+changes to initialStateName, and then keeps changing to a random destination of the current state (from a seeded RNG), until
+a final state (see smg.GetDefinition().GetFinalStates()) or maxSteps is reached.
+
+When an invariant is broken, the sequence of states is shrunk to the shortest sequence found that still breaks the same invariant,
+which is reported in a SimulationFailure.
+
+	sim := NewSimulator(newSmx, "Init").AddInvariant(InvariantErrorOnlyIn("^Finished"))
+	for seed := int64(0); seed < 1000; seed++ {
+		if failure := sim.Run(seed, 50); failure != nil {
+			t.Fatal(failure)
+		}
+	}
+
+To use go native fuzzing, see sim.Fuzz()
+*/
+type Simulator struct {
+	newSmx           func() (*stateMxn.StateMxnGeneric, error)
+	initialStateName string
+	invariants       []Invariant
+
+	// maximum number of replays done while shrinking a failing sequence
+	maxShrinkReplays int
+}
+
+func NewSimulator(newSmx func() (*stateMxn.StateMxnGeneric, error), initialStateName string) *Simulator {
+	return &Simulator{
+		newSmx:           newSmx,
+		initialStateName: initialStateName,
+		maxShrinkReplays: 10000,
+	}
+}
+
+func (sim *Simulator) AddInvariant(invariants ...Invariant) *Simulator {
+	sim.invariants = append(sim.invariants, invariants...)
+	return sim
+}
+
+// SimulationFailure describes a broken invariant. Sequences are the states changed-into, starting with the initial state
+type SimulationFailure struct {
+	Seed             int64
+	InvariantName    string
+	Err              error
+	Sequence         []string
+	ShortestSequence []string
+
+	// StatesFlow is the DisplayStatesFlow() of the smachine that ran the ShortestSequence
+	StatesFlow string
+}
+
+func (sf *SimulationFailure) String() string {
+	return fmt.Sprintf("invariant '%s' broken (seed %d): %s\nsequence: %s\nshortest reproducing sequence: %s\n%s",
+		sf.InvariantName, sf.Seed, sf.Err,
+		strings.Join(sf.Sequence, " -> "),
+		strings.Join(sf.ShortestSequence, " -> "),
+		sf.StatesFlow)
+}
+
+func (sf *SimulationFailure) Error() string {
+	return sf.String()
+}
+
+// Run does one random-walk simulation with the given seed, of at most maxSteps state-changes (after the initial state).
+// Returns nil if no invariant was broken. Returns a SimulationFailure with Err set, if newSmx() failed or the change into the
+// initial state was rejected
+func (sim *Simulator) Run(seed int64, maxSteps int) *SimulationFailure {
+	rng := rand.New(rand.NewSource(seed))
+	return sim.walk(seed, maxSteps, func(nDestinations int) (int, bool) {
+		return rng.Intn(nDestinations), true
+	})
+}
+
+// RunChoices is like sim.Run() but the destination of each step is choices[i] % <number of destinations of current state>.
+// The walk stops when choices are exhausted. Used by sim.Fuzz()
+func (sim *Simulator) RunChoices(choices []byte) *SimulationFailure {
+	i := 0
+	return sim.walk(0, len(choices), func(nDestinations int) (int, bool) {
+		if i >= len(choices) {
+			return 0, false
+		}
+		choice := int(choices[i]) % nDestinations
+		i++
+		return choice, true
+	})
+}
+
+/*
+Fuzz plugs the simulator into go native fuzzing, where each fuzz input is a sequence of transition choices (see sim.RunChoices())
+
+	func FuzzMySmx(f *testing.F) {
+		sim := stateMxntest.NewSimulator(newSmx, "Init").AddInvariant(stateMxntest.InvariantErrorOnlyIn("^Finished"))
+		sim.Fuzz(f)
+	}
+*/
+func (sim *Simulator) Fuzz(f *testing.F) {
+	f.Add([]byte{})
+	f.Add([]byte{0, 0, 0, 0})
+	f.Add([]byte{1, 1, 1, 1})
+	f.Fuzz(func(t *testing.T, choices []byte) {
+		if failure := sim.RunChoices(choices); failure != nil {
+			t.Fatal(failure)
+		}
+	})
+}
+
+// chooseFunc returns the index of the destination to change into, or false to stop the walk
+func (sim *Simulator) walk(seed int64, maxSteps int, chooseFunc func(nDestinations int) (int, bool)) *SimulationFailure {
+	smg, err := sim.newSmx()
+	if err != nil {
+		return &SimulationFailure{Seed: seed, InvariantName: "newSmx", Err: err}
+	}
+	sequence := []string{sim.initialStateName}
+	err = smg.Change(sim.initialStateName)
+	if smg.GetCurrentState() == nil || smg.GetCurrentState().GetName() != sim.initialStateName {
+		// the initial state-change was rejected, so there is nothing to walk from
+		return &SimulationFailure{
+			Seed:          seed,
+			InvariantName: "initialState",
+			Err:           fmt.Errorf("could not change into initial state '%s': %w", sim.initialStateName, err),
+			Sequence:      sequence,
+			StatesFlow:    smg.GetHistoryOfStates().DisplayStatesFlow(),
+		}
+	}
+	if invariant, err := sim.checkInvariants(smg); err != nil {
+		return sim.newFailure(seed, invariant, err, sequence)
+	}
+	finalStateNames := smg.GetDefinition().GetFinalStates()
+	for step := 0; step < maxSteps; step++ {
+		if isFinalState(finalStateNames, smg.GetCurrentState().GetName()) {
+			break
+		}
+		destinations := smg.GetTransitionsMap()[smg.GetCurrentState().GetName()]
+		choice, ok := chooseFunc(len(destinations))
+		if !ok {
+			break
+		}
+		sequence = append(sequence, destinations[choice])
+		_ = smg.Change(destinations[choice])
+		if invariant, err := sim.checkInvariants(smg); err != nil {
+			return sim.newFailure(seed, invariant, err, sequence)
+		}
+	}
+	return nil
+}
+
+func isFinalState(finalStateNames []string, stateName string) bool {
+	for _, name := range finalStateNames {
+		if name == stateName {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the first broken invariant and its error
+func (sim *Simulator) checkInvariants(smg *stateMxn.StateMxnGeneric) (Invariant, error) {
+	for _, invariant := range sim.invariants {
+		if err := invariant.Check(smg); err != nil {
+			return invariant, err
+		}
+	}
+	return Invariant{}, nil
+}
+
+// Replays sequence in a fresh smachine. Returns the smachine, and the invariant broken at the last state of the sequence (if any).
+// Returns ok=false if the sequence could not be replayed (invalid state-change, or an invariant broken before the last state)
+func (sim *Simulator) replay(sequence []string) (smg *stateMxn.StateMxnGeneric, invariant Invariant, invErr error, ok bool) {
+	smg, err := sim.newSmx()
+	if err != nil {
+		return nil, Invariant{}, nil, false
+	}
+	for i, stateName := range sequence {
+		_ = smg.Change(stateName)
+		if smg.GetCurrentState() == nil || smg.GetCurrentState().GetName() != stateName {
+			// the state-change was rejected
+			return smg, Invariant{}, nil, false
+		}
+		invariant, invErr = sim.checkInvariants(smg)
+		if invErr != nil {
+			return smg, invariant, invErr, i == len(sequence)-1
+		}
+	}
+	return smg, Invariant{}, nil, true
+}
+
+func (sim *Simulator) newFailure(seed int64, invariant Invariant, err error, sequence []string) *SimulationFailure {
+	shortestSequence := sim.shrink(sequence, invariant.Name)
+	smg, _, _, _ := sim.replay(shortestSequence)
+	statesFlow := ""
+	if smg != nil {
+		statesFlow = smg.GetHistoryOfStates().DisplayStatesFlow()
+	}
+	return &SimulationFailure{
+		Seed:             seed,
+		InvariantName:    invariant.Name,
+		Err:              err,
+		Sequence:         sequence,
+		ShortestSequence: shortestSequence,
+		StatesFlow:       statesFlow,
+	}
+}
+
+// Returns the shortest sequence found that breaks the invariant invariantName at its last state:
+//   - first, cycles are removed from sequence (ex: A B C B D -> A B D) while it still breaks the invariant
+//   - then, a breadth-first search looks for shorter sequences from the initial state (limited to sim.maxShrinkReplays replays)
+func (sim *Simulator) shrink(sequence []string, invariantName string) []string {
+	reproduces := func(candidate []string) bool {
+		_, invariant, invErr, ok := sim.replay(candidate)
+		return ok && invErr != nil && invariant.Name == invariantName
+	}
+
+	shortest := sequence
+	replays := 0
+
+	// remove cycles
+	for removedCycle := true; removedCycle; {
+		removedCycle = false
+		for i := 0; i < len(shortest) && !removedCycle; i++ {
+			for j := len(shortest) - 1; j > i && !removedCycle; j-- {
+				if shortest[i] != shortest[j] {
+					continue
+				}
+				candidate := append(append([]string{}, shortest[:i+1]...), shortest[j+1:]...)
+				replays++
+				if reproduces(candidate) {
+					shortest = candidate
+					removedCycle = true
+				}
+			}
+		}
+	}
+
+	// breadth-first search of shorter sequences
+	smg, err := sim.newSmx()
+	if err != nil {
+		return shortest
+	}
+	transitionsMap := smg.GetTransitionsMap()
+	frontier := [][]string{{sim.initialStateName}}
+	for len(frontier) > 0 && len(frontier[0]) < len(shortest) && replays < sim.maxShrinkReplays {
+		var nextFrontier [][]string
+		for _, candidate := range frontier {
+			if replays >= sim.maxShrinkReplays {
+				break
+			}
+			replays++
+			if reproduces(candidate) {
+				return candidate
+			}
+			for _, destination := range transitionsMap[candidate[len(candidate)-1]] {
+				nextFrontier = append(nextFrontier, append(append([]string{}, candidate...), destination))
+			}
+		}
+		frontier = nextFrontier
+	}
+	return shortest
+}
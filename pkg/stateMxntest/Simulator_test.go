@@ -0,0 +1,129 @@
+package stateMxntest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/zipizapclouds/stateMxn/pkg/stateMxn"
+)
+
+// Init -> A <-> B -> Done, and A -> Broken -> Done where Broken fails
+func newSimSmx() (*stateMxn.StateMxnGeneric, error) {
+	broken := stateMxn.NewState("Broken")
+	broken.AddHandlerExec(StubErr("Broken always fails"))
+	return stateMxn.NewStateMxnGeneric("simSmx", map[string][]string{
+		"Init":   {"A", "B"},
+		"A":      {"B", "Broken", "Done"},
+		"B":      {"A", "Done"},
+		"Broken": {"Done"},
+	}, map[string]stateMxn.StateIfc{"Broken": broken})
+}
+
+func TestSimulatorFindsAndShrinksBrokenInvariant(t *testing.T) {
+	sim := NewSimulator(newSimSmx, "Init").AddInvariant(InvariantErrorOnlyIn("^Done"))
+	var failure *SimulationFailure
+	for seed := int64(0); seed < 100 && failure == nil; seed++ {
+		failure = sim.Run(seed, 50)
+	}
+	if failure == nil {
+		t.Fatal("no simulation reached state Broken")
+	}
+	if failure.InvariantName != "error only in ^Done" {
+		t.Errorf("InvariantName = %q", failure.InvariantName)
+	}
+	if got := strings.Join(failure.ShortestSequence, " "); got != "Init A Broken" {
+		t.Errorf("ShortestSequence = %q - want \"Init A Broken\" (sequence was %v)", got, failure.Sequence)
+	}
+	if !strings.Contains(failure.StatesFlow, "Broken") {
+		t.Errorf("StatesFlow does not show the failing state:\n%s", failure.StatesFlow)
+	}
+}
+
+func TestSimulatorRunIsDeterministic(t *testing.T) {
+	sim := NewSimulator(newSimSmx, "Init").AddInvariant(InvariantErrorOnlyIn("^Done"))
+	for seed := int64(0); seed < 20; seed++ {
+		f1, f2 := sim.Run(seed, 50), sim.Run(seed, 50)
+		if (f1 == nil) != (f2 == nil) || (f1 != nil && strings.Join(f1.Sequence, " ") != strings.Join(f2.Sequence, " ")) {
+			t.Fatalf("seed %d: different results %v / %v", seed, f1, f2)
+		}
+	}
+}
+
+func TestSimulatorRunChoices(t *testing.T) {
+	sim := NewSimulator(newSimSmx, "Init").AddInvariant(InvariantErrorOnlyIn("^Done"))
+	// Init -(0)-> A -(2)-> Done
+	if failure := sim.RunChoices([]byte{0, 2}); failure != nil {
+		t.Errorf("RunChoices(0, 2) = %v", failure)
+	}
+	// Init -(0)-> A -(1)-> Broken
+	if failure := sim.RunChoices([]byte{0, 1}); failure == nil {
+		t.Error("RunChoices(0, 1) did not break the invariant")
+	}
+}
+
+func TestSimulatorRejectedInitialState(t *testing.T) {
+	sim := NewSimulator(newSimSmx, "Bogus")
+	failure := sim.Run(0, 10)
+	if failure == nil {
+		t.Fatal("Run() from an unknown initial state did not fail")
+	}
+	if failure.InvariantName != "initialState" || !errors.Is(failure.Err, stateMxn.ErrUnknownState) {
+		t.Errorf("failure = %s", failure)
+	}
+}
+
+func TestSimulatorNewSmxFailure(t *testing.T) {
+	errNewSmx := errors.New("cannot create smx")
+	sim := NewSimulator(func() (*stateMxn.StateMxnGeneric, error) { return nil, errNewSmx }, "Init")
+	if failure := sim.Run(0, 10); failure == nil || failure.Err != errNewSmx {
+		t.Errorf("failure = %v - want Err %v", failure, errNewSmx)
+	}
+}
+
+// Without the Broken state, every walk keeps the invariant
+func FuzzSimulator(f *testing.F) {
+	sim := NewSimulator(func() (*stateMxn.StateMxnGeneric, error) {
+		return stateMxn.NewStateMxnGeneric("fuzzSmx", map[string][]string{
+			"Init": {"A", "B"},
+			"A":    {"B", "Done"},
+			"B":    {"A", "Done"},
+		}, nil)
+	}, "Init").AddInvariant(InvariantErrorOnlyIn("^Done"))
+	sim.Fuzz(f)
+}
+
+func TestSimulatorStopsAtTheFinalStates(t *testing.T) {
+	newSmx := func() (*stateMxn.StateMxnGeneric, error) {
+		def, err := stateMxn.NewStateMxnDefinitionWithOpts("finalsSmx", map[string][]string{
+			"Init":    {"Running", "FinishedNok"},
+			"Running": {"Init", "FinishedOk", "FinishedNok"},
+		}, nil, &stateMxn.StateMxnDefinitionOpts{FinalStates: []string{"FinishedOk", "FinishedNok"}})
+		if err != nil {
+			return nil, err
+		}
+		return def.NewInstance("finalsSmx"), nil
+	}
+	var visited []string
+	sim := NewSimulator(newSmx, "Init").AddInvariant(Invariant{
+		Name: "record visited states",
+		Check: func(smg *stateMxn.StateMxnGeneric) error {
+			visited = append(visited, smg.GetCurrentState().GetName())
+			return nil
+		},
+	})
+	for seed := int64(0); seed < 20; seed++ {
+		visited = nil
+		if failure := sim.Run(seed, 1000); failure != nil {
+			t.Fatal(failure)
+		}
+		if last := visited[len(visited)-1]; !strings.HasPrefix(last, "Finished") {
+			t.Fatalf("seed %d: walk %v did not stop at a final state", seed, visited)
+		}
+		for _, stateName := range visited[:len(visited)-1] {
+			if strings.HasPrefix(stateName, "Finished") {
+				t.Fatalf("seed %d: walk %v did not stop at the first final state", seed, visited)
+			}
+		}
+	}
+}
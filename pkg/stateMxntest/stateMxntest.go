@@ -0,0 +1,243 @@
+/*
+Package stateMxntest contains helpers to test smachines of package stateMxn.
+
+After running a smachine, use the Assert*() functions to check the path of states it went through, its final state,
+error and outputs. Every failure message includes the smachine DisplayStatesFlow(), to ease debugging.
+
+	AssertPath(t, smx, "Init", "Running", "Finished.*")
+	AssertPath(t, Enclosed(t, smx, "stateEnclosingSmxInner"), "Init", "Running", "FinishedNok")
+	AssertFinalState(t, smx, "FinishedNok")
+	AssertError(t, smx, "simulating error")
+
+Use StubHandler to have handlers that succeed or return errors on demand.
+*/
+package stateMxntest
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zipizapclouds/stateMxn/pkg/stateMxn"
+)
+
+// AssertPath checks that the historyOfStates of smx matches exactly the given path
+//
+// Each path element is a regexp RE2 (https://golang.org/s/re2syntax) that must match the whole state name
+// (ie, "Finished" does not match "FinishedOk" but "Finished.*" does)
+//
+// To assert the path of an enclosedSmx, use AssertPath(t, Enclosed(t, smx, "<enclosingStateName>"), ...)
+func AssertPath(t testing.TB, smx stateMxn.StateMxnIfc, path ...string) bool {
+	t.Helper()
+	hos := smx.GetHistoryOfStates()
+	if len(hos) != len(path) {
+		t.Errorf("smx '%s': path has %d states, expected %d %v\n%s", smx.GetName(), len(hos), len(path), path, displayStatesFlow(smx))
+		return false
+	}
+	for i, stateRegexp := range path {
+		ok, err := matchWhole(stateRegexp, hos[i].GetName())
+		if err != nil {
+			t.Errorf("smx '%s': invalid regexp '%s' at path[%d]: %s", smx.GetName(), stateRegexp, i, err)
+			return false
+		}
+		if !ok {
+			t.Errorf("smx '%s': path[%d] is state '%s', expected '%s'\n%s", smx.GetName(), i, hos[i].GetName(), stateRegexp, displayStatesFlow(smx))
+			return false
+		}
+	}
+	return true
+}
+
+// Enclosed returns the enclosedSmx of the last state (of the historyOfStates of smx) that matches enclosingStatePath
+//
+// enclosingStatePath is a "/" separated list of regexps, one for each nesting level.
+// Ex: "stateEnclosingA/stateEnclosingB" returns the smx enclosed in stateEnclosingB, which is inside the smx enclosed in stateEnclosingA
+//
+// If not found, the test is failed with t.Fatalf()
+func Enclosed(t testing.TB, smx stateMxn.StateMxnIfc, enclosingStatePath string) stateMxn.StateMxnIfc {
+	t.Helper()
+	outterSmx := smx
+	for _, stateRegexp := range strings.Split(enclosingStatePath, "/") {
+		var eSmx stateMxn.StateMxnIfc
+		hos := outterSmx.GetHistoryOfStates()
+		for i := len(hos) - 1; i >= 0; i-- {
+			ok, err := matchWhole(stateRegexp, hos[i].GetName())
+			if err != nil {
+				t.Fatalf("smx '%s': invalid regexp '%s' in enclosingStatePath '%s': %s", outterSmx.GetName(), stateRegexp, enclosingStatePath, err)
+			}
+			if !ok {
+				continue
+			}
+			if eSmx, ok = hos[i].GetData()["enclosedSmx"].(stateMxn.StateMxnIfc); ok {
+				break
+			}
+		}
+		if eSmx == nil {
+			t.Fatalf("smx '%s': no state matching '%s' with an enclosedSmx\n%s", outterSmx.GetName(), stateRegexp, displayStatesFlow(smx))
+		}
+		outterSmx = eSmx
+	}
+	return outterSmx
+}
+
+// AssertFinalState checks that the current state of smx matches (the whole name) stateRegexp
+func AssertFinalState(t testing.TB, smx stateMxn.StateMxnIfc, stateRegexp string) bool {
+	t.Helper()
+	if smx.GetCurrentState() == nil {
+		t.Errorf("smx '%s': has no current state, expected '%s'", smx.GetName(), stateRegexp)
+		return false
+	}
+	ok, err := matchWhole(stateRegexp, smx.GetCurrentState().GetName())
+	if err != nil {
+		t.Errorf("smx '%s': invalid regexp '%s': %s", smx.GetName(), stateRegexp, err)
+		return false
+	}
+	if !ok {
+		t.Errorf("smx '%s': final state is '%s', expected '%s'\n%s", smx.GetName(), smx.GetCurrentState().GetName(), stateRegexp, displayStatesFlow(smx))
+		return false
+	}
+	return true
+}
+
+// AssertError checks that smx.GetData()["error"] is set, and that its message matches errRegexp (unanchored, like regexp.MatchString)
+func AssertError(t testing.TB, smx stateMxn.StateMxnIfc, errRegexp string) bool {
+	t.Helper()
+	err := smxError(smx)
+	if err == nil {
+		t.Errorf("smx '%s': has no error, expected error matching '%s'\n%s", smx.GetName(), errRegexp, displayStatesFlow(smx))
+		return false
+	}
+	ok, rerr := regexp.MatchString(errRegexp, err.Error())
+	if rerr != nil {
+		t.Errorf("smx '%s': invalid regexp '%s': %s", smx.GetName(), errRegexp, rerr)
+		return false
+	}
+	if !ok {
+		t.Errorf("smx '%s': error '%s' does not match '%s'\n%s", smx.GetName(), err, errRegexp, displayStatesFlow(smx))
+		return false
+	}
+	return true
+}
+
+// AssertNoError checks that smx.GetData()["error"] is not set
+func AssertNoError(t testing.TB, smx stateMxn.StateMxnIfc) bool {
+	t.Helper()
+	if err := smxError(smx); err != nil {
+		t.Errorf("smx '%s': unexpected error '%s'\n%s", smx.GetName(), err, displayStatesFlow(smx))
+		return false
+	}
+	return true
+}
+
+// AssertOutputs checks that the outputs of the current state of smx contain all the keys of expectedOutputs, with
+// values equal (reflect.DeepEqual) to those of expectedOutputs. Other keys in the outputs are ignored
+func AssertOutputs(t testing.TB, smx stateMxn.StateMxnIfc, expectedOutputs stateMxn.StateOutputs) bool {
+	t.Helper()
+	if smx.GetCurrentState() == nil {
+		t.Errorf("smx '%s': has no current state, expected outputs %v", smx.GetName(), expectedOutputs)
+		return false
+	}
+	outputs := smx.GetCurrentState().GetOutputs()
+	allOk := true
+	for k, expectedV := range expectedOutputs {
+		v, ok := outputs[k]
+		if !ok {
+			t.Errorf("smx '%s': state '%s' has no outputs[%s], expected %#v\n%s", smx.GetName(), smx.GetCurrentState().GetName(), k, expectedV, displayStatesFlow(smx))
+			allOk = false
+			continue
+		}
+		if !reflect.DeepEqual(v, expectedV) {
+			t.Errorf("smx '%s': state '%s' has outputs[%s] = %#v, expected %#v\n%s", smx.GetName(), smx.GetCurrentState().GetName(), k, v, expectedV, displayStatesFlow(smx))
+			allOk = false
+		}
+	}
+	return allOk
+}
+
+// Returns true if stateRegexp matches the whole stateName
+func matchWhole(stateRegexp string, stateName string) (bool, error) {
+	return regexp.MatchString("^(?:"+stateRegexp+")$", stateName)
+}
+
+func smxError(smx stateMxn.StateMxnIfc) error {
+	err, _ := smx.GetData()["error"].(error)
+	return err
+}
+
+func displayStatesFlow(smx stateMxn.StateMxnIfc) string {
+	return "===== " + smx.GetName() + " DisplayStatesFlow =====\n" + smx.GetHistoryOfStates().DisplayStatesFlow()
+}
+
+/*
+StubHandler is a stateMxn.StateHandler that succeeds or returns errors on demand, and counts how many times it was called
+
+	stub := NewStubHandler().FailOnCall(2, fmt.Errorf("2nd call fails"))
+	state.AddHandlerExec(stub.Handler())
+
+Its safe for concurrent use (ex: handlers called from timed-transitions or from a smachine in actor-mode)
+*/
+type StubHandler struct {
+	mu          sync.Mutex
+	err         error
+	failOnCalls map[int]error
+	calls       int
+}
+
+func NewStubHandler() *StubHandler {
+	return &StubHandler{
+		failOnCalls: make(map[int]error),
+	}
+}
+
+// FailWith makes every following call return err (a nil err makes them succeed)
+func (sh *StubHandler) FailWith(err error) *StubHandler {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.err = err
+	return sh
+}
+
+// Succeed makes every following call succeed (except the ones set with sh.FailOnCall())
+func (sh *StubHandler) Succeed() *StubHandler {
+	return sh.FailWith(nil)
+}
+
+// FailOnCall makes the nth call (starting at 1) return err, regardless of sh.FailWith()
+func (sh *StubHandler) FailOnCall(n int, err error) *StubHandler {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	sh.failOnCalls[n] = err
+	return sh
+}
+
+// Calls returns how many times the handler was called
+func (sh *StubHandler) Calls() int {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	return sh.calls
+}
+
+func (sh *StubHandler) Handler() stateMxn.StateHandler {
+	return func(inputs stateMxn.StateInputs, outputs stateMxn.StateOutputs, stateData stateMxn.StateData, smData stateMxn.StateMxnData) error {
+		sh.mu.Lock()
+		defer sh.mu.Unlock()
+		sh.calls++
+		if err, ok := sh.failOnCalls[sh.calls]; ok {
+			return err
+		}
+		return sh.err
+	}
+}
+
+// StubOk returns a handler that always succeeds
+func StubOk() stateMxn.StateHandler {
+	return NewStubHandler().Handler()
+}
+
+// StubErr returns a handler that always returns an error with the given message
+func StubErr(format string, a ...interface{}) stateMxn.StateHandler {
+	return NewStubHandler().FailWith(fmt.Errorf(format, a...)).Handler()
+}
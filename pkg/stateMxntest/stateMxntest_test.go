@@ -0,0 +1,199 @@
+package stateMxntest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/zipizapclouds/stateMxn/pkg/stateMxn"
+)
+
+// recordingTB records the failures of the Assert*() functions, instead of failing the test.
+// Fatalf() stops the calling goroutine like testing.T does, so use it with recordingTB.run()
+type recordingTB struct {
+	testing.TB
+	errors []string
+	fatal  bool
+}
+
+func (rtb *recordingTB) Helper() {}
+
+func (rtb *recordingTB) Errorf(format string, args ...interface{}) {
+	rtb.errors = append(rtb.errors, fmt.Sprintf(format, args...))
+}
+
+type fatalSentinel struct{}
+
+func (rtb *recordingTB) Fatalf(format string, args ...interface{}) {
+	rtb.Errorf(format, args...)
+	rtb.fatal = true
+	panic(fatalSentinel{})
+}
+
+func (rtb *recordingTB) run(f func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(fatalSentinel); !ok {
+				panic(r)
+			}
+		}
+	}()
+	f()
+}
+
+func (rtb *recordingTB) failed() bool {
+	return len(rtb.errors) > 0
+}
+
+// Init -> Running -> FinishedOk, or FinishedNok when runningHandler fails
+func newTestSmx(t *testing.T, runningHandler stateMxn.StateHandler) *stateMxn.StateMxnSimpleflow {
+	t.Helper()
+	running := stateMxn.NewState("Running")
+	running.AddHandlerExec(runningHandler)
+	smx, err := stateMxn.NewStateMxnSimpleFlow("testSmx", map[string][]string{
+		"Init":    {"Running", "FinishedNok"},
+		"Running": {"FinishedOk", "FinishedNok"},
+	}, map[string]stateMxn.StateIfc{"Running": running})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return smx
+}
+
+func TestAssertionsOnSuccessfulRun(t *testing.T) {
+	smx := newTestSmx(t, func(inputs stateMxn.StateInputs, outputs stateMxn.StateOutputs, stateData stateMxn.StateData, smData stateMxn.StateMxnData) error {
+		outputs["result"] = 42
+		return nil
+	})
+	if err := smx.ChangeToInitialStateAndAutoprogressToOtherStates("Init"); err != nil {
+		t.Fatal(err)
+	}
+
+	AssertPath(t, smx, "Init", "Running", "Finished.*")
+	AssertFinalState(t, smx, "FinishedOk")
+	AssertNoError(t, smx)
+
+	rtb := &recordingTB{}
+	if AssertPath(rtb, smx, "Init", "Finished") || AssertPath(rtb, smx, "Init", "Running", "Finished") {
+		t.Error("AssertPath() succeeded with a wrong path")
+	}
+	if AssertFinalState(rtb, smx, "FinishedNok") {
+		t.Error("AssertFinalState() succeeded with a wrong state")
+	}
+	if AssertError(rtb, smx, ".*") {
+		t.Error("AssertError() succeeded without error")
+	}
+	if len(rtb.errors) != 4 {
+		t.Errorf("recorded %d failures - want 4: %v", len(rtb.errors), rtb.errors)
+	}
+	if !strings.Contains(rtb.errors[0], "DisplayStatesFlow") {
+		t.Errorf("failure message does not include the DisplayStatesFlow: %s", rtb.errors[0])
+	}
+}
+
+func TestAssertionsOnFailedRun(t *testing.T) {
+	smx := newTestSmx(t, StubErr("simulating error %d", 1))
+	_ = smx.ChangeToInitialStateAndAutoprogressToOtherStates("Init")
+
+	AssertPath(t, smx, "Init", "Running", "FinishedNok")
+	AssertError(t, smx, "simulating error 1")
+
+	rtb := &recordingTB{}
+	if AssertNoError(rtb, smx) {
+		t.Error("AssertNoError() succeeded with an error")
+	}
+	if AssertError(rtb, smx, "another error") {
+		t.Error("AssertError() succeeded with a non-matching regexp")
+	}
+	if AssertOutputs(rtb, smx, stateMxn.StateOutputs{"result": 42}) {
+		t.Error("AssertOutputs() succeeded with a missing output")
+	}
+}
+
+func TestAssertOutputs(t *testing.T) {
+	smx := newTestSmx(t, func(inputs stateMxn.StateInputs, outputs stateMxn.StateOutputs, stateData stateMxn.StateData, smData stateMxn.StateMxnData) error {
+		outputs["result"] = []int{4, 2}
+		return nil
+	})
+	// the outputs of Running are passed as inputs to FinishedOk, which has no handlers - so check Running itself
+	_ = smx.ChangeToInitialStateAndAutoprogressToOtherStates("Init")
+	running := smx.GetHistoryOfStates()[1]
+	if got := running.GetOutputs()["result"]; fmt.Sprint(got) != "[4 2]" {
+		t.Fatalf("outputs[result] = %v", got)
+	}
+
+	rtb := &recordingTB{}
+	if AssertOutputs(rtb, smx, stateMxn.StateOutputs{"result": []int{4, 2}}) {
+		t.Error("AssertOutputs() succeeded on the final state, which has no outputs")
+	}
+}
+
+func TestEnclosed(t *testing.T) {
+	inner := newTestSmx(t, StubOk())
+	outter, err := stateMxn.NewStateMxnSimpleFlow("outter", map[string][]string{
+		"stateEnclosingInner": {"Done"},
+	}, map[string]stateMxn.StateIfc{
+		"stateEnclosingInner": stateMxn.NewStateEnclosingSmxSimpleflow("stateEnclosingInner", inner, "Init"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = outter.ChangeToInitialStateAndAutoprogressToOtherStates("stateEnclosingInner")
+
+	AssertPath(t, Enclosed(t, outter, "stateEnclosing.*"), "Init", "Running", "FinishedOk")
+
+	rtb := &recordingTB{}
+	rtb.run(func() { Enclosed(rtb, outter, "Done") })
+	if !rtb.fatal {
+		t.Error("Enclosed() did not fail for a state without enclosedSmx")
+	}
+}
+
+func TestStubHandler(t *testing.T) {
+	errEvery := errors.New("every")
+	errSecond := errors.New("second")
+	stub := NewStubHandler().FailOnCall(2, errSecond)
+	handler := stub.Handler()
+
+	call := func() error { return handler(nil, nil, nil, nil) }
+	if err := call(); err != nil {
+		t.Errorf("call 1 = %v - want nil", err)
+	}
+	if err := call(); err != errSecond {
+		t.Errorf("call 2 = %v - want %v", err, errSecond)
+	}
+	stub.FailWith(errEvery)
+	if err := call(); err != errEvery {
+		t.Errorf("call 3 = %v - want %v", err, errEvery)
+	}
+	stub.Succeed()
+	if err := call(); err != nil {
+		t.Errorf("call 4 = %v - want nil", err)
+	}
+	if stub.Calls() != 4 {
+		t.Errorf("Calls() = %d - want 4", stub.Calls())
+	}
+}
+
+// run with -race
+func TestStubHandlerConcurrentCalls(t *testing.T) {
+	stub := NewStubHandler()
+	handler := stub.Handler()
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				_ = handler(nil, nil, nil, nil)
+				_ = stub.Calls()
+			}
+		}()
+	}
+	wg.Wait()
+	if stub.Calls() != 800 {
+		t.Errorf("Calls() = %d - want 800", stub.Calls())
+	}
+}